@@ -23,7 +23,7 @@ func TestString(t *testing.T) {
 		},
 	}
 
-	log.Printf(program.String())
+	log.Printf("%s", program.String())
 
 	if program.String() != "let myVar = anotherVar;" {
 		t.Errorf("program.String() wrong. got=%q", program.String())
@@ -0,0 +1,762 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"token"
+)
+
+// The JSON form of a program is meant for tools written in other languages.
+// Every node is an object whose "kind" is the name of its Go type, followed by
+// its source range "pos" and "end" and then its fields: "token" and the other
+// tokens of the node, and its children under their field names in camel case.
+// Keys always appear, in that order; missing children and invalid positions
+// are null. Tokens are objects with "type", "literal", "pos" and "end", and
+// positions objects with "line", "column" and "offset". The file name is
+// given once, in the "filename" of the program.
+//
+// For example, `x + 1` is the statement
+//
+//	{"kind": "ExpressionStatement", "pos": ..., "end": ...,
+//	 "token": {"type": "IDENT", "literal": "x", "pos": ..., "end": ...},
+//	 "expression": {"kind": "InfixExpression", ..., "operator": "+",
+//	                "left": {"kind": "Identifier", ...}, "right": ...}}
+//
+// "pos" and "end" are ignored when a program is read back, since they follow
+// from the tokens.
+
+// MarshalJSON encodes the program in the JSON form described above.
+func (p *Program) MarshalJSON() ([]byte, error) {
+	e := &encoder{filename: p.Pos().Filename}
+	if len(p.Comments) > 0 {
+		e.filename = p.Comments[0].Pos().Filename
+	}
+	return marshal(object{
+		{"kind", "Program"},
+		{"filename", e.filename},
+		{"pos", e.position(p.Pos())},
+		{"end", e.position(p.End())},
+		{"statements", e.statements(p.Statements)},
+		{"comments", e.comments(p.Comments)},
+	})
+}
+
+// UnmarshalJSON replaces the program with the one encoded in data by
+// MarshalJSON. It fails for a tree the parser cannot produce, like a let
+// statement without a value, rather than leaving the engines a nil child.
+func (p *Program) UnmarshalJSON(data []byte) error {
+	var f fields
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	d := &decoder{}
+	if kind := d.string(f["kind"]); d.err == nil && kind != "Program" {
+		return fmt.Errorf("ast: expected a Program, got %q", kind)
+	}
+	d.filename = d.string(f["filename"])
+	statements := d.statements(f["statements"])
+	comments := d.comments(f["comments"])
+	if d.err != nil {
+		return d.err
+	}
+	p.Statements = statements
+	p.Comments = comments
+	return nil
+}
+
+// object is a JSON object that keeps its keys in order.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+
+	out.WriteString("{")
+	for i, m := range o {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		value, err := marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(strconv.Quote(m.key))
+		out.WriteString(":")
+		out.Write(value)
+	}
+	out.WriteString("}")
+
+	return out.Bytes(), nil
+}
+
+// marshal is json.Marshal without the escaping of <, > and &, which would
+// only make the strings of the program harder to read.
+func marshal(v interface{}) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+type encoder struct {
+	filename string
+}
+
+func (e *encoder) position(pos token.Position) interface{} {
+	if !pos.IsValid() {
+		return nil
+	}
+	return object{{"line", pos.Line}, {"column", pos.Column}, {"offset", pos.Offset}}
+}
+
+func (e *encoder) token(t token.Token) interface{} {
+	if t == (token.Token{}) {
+		return nil
+	}
+	return object{
+		{"type", string(t.Type)},
+		{"literal", t.Literal},
+		{"pos", e.position(t.Pos)},
+		{"end", e.position(t.End)},
+	}
+}
+
+func (e *encoder) statements(statements []Statement) interface{} {
+	if statements == nil {
+		return nil
+	}
+	list := make([]interface{}, len(statements))
+	for i, s := range statements {
+		list[i] = e.node(s)
+	}
+	return list
+}
+
+func (e *encoder) expressions(expressions []Expression) interface{} {
+	if expressions == nil {
+		return nil
+	}
+	list := make([]interface{}, len(expressions))
+	for i, x := range expressions {
+		list[i] = e.node(x)
+	}
+	return list
+}
+
+func (e *encoder) identifiers(identifiers []*Identifier) interface{} {
+	if identifiers == nil {
+		return nil
+	}
+	list := make([]interface{}, len(identifiers))
+	for i, id := range identifiers {
+		list[i] = e.identifier(id)
+	}
+	return list
+}
+
+func (e *encoder) comments(comments []*Comment) interface{} {
+	if comments == nil {
+		return nil
+	}
+	list := make([]interface{}, len(comments))
+	for i, c := range comments {
+		list[i] = object{
+			{"kind", "Comment"},
+			{"pos", e.position(c.Pos())},
+			{"end", e.position(c.End())},
+			{"token", e.token(c.Token)},
+		}
+	}
+	return list
+}
+
+// identifier and block spare the callers from turning nil pointers into
+// non-nil Nodes.
+func (e *encoder) identifier(id *Identifier) interface{} {
+	if id == nil {
+		return nil
+	}
+	return e.node(id)
+}
+
+func (e *encoder) block(block *BlockStatement) interface{} {
+	if block == nil {
+		return nil
+	}
+	return e.node(block)
+}
+
+func (e *encoder) node(node Node) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	o := object{{"kind", ""}, {"pos", e.position(node.Pos())}, {"end", e.position(node.End())}}
+	kind := func(name string, members ...member) object {
+		o[0].value = name
+		return append(o, members...)
+	}
+
+	switch node := node.(type) {
+	case *LetStatement:
+		return kind("LetStatement",
+			member{"token", e.token(node.Token)},
+			member{"name", e.identifier(node.Name)},
+			member{"value", e.node(node.Value)})
+	case *ReturnStatement:
+		return kind("ReturnStatement",
+			member{"token", e.token(node.Token)},
+			member{"returnValue", e.node(node.ReturnValue)})
+	case *ThrowStatement:
+		return kind("ThrowStatement",
+			member{"token", e.token(node.Token)},
+			member{"value", e.node(node.Value)})
+	case *WhileStatement:
+		return kind("WhileStatement",
+			member{"token", e.token(node.Token)},
+			member{"condition", e.node(node.Condition)},
+			member{"body", e.block(node.Body)})
+	case *ForStatement:
+		return kind("ForStatement",
+			member{"token", e.token(node.Token)},
+			member{"variable", e.identifier(node.Variable)},
+			member{"iterable", e.node(node.Iterable)},
+			member{"body", e.block(node.Body)})
+	case *BreakStatement:
+		return kind("BreakStatement", member{"token", e.token(node.Token)})
+	case *ContinueStatement:
+		return kind("ContinueStatement", member{"token", e.token(node.Token)})
+	case *ExpressionStatement:
+		return kind("ExpressionStatement",
+			member{"token", e.token(node.Token)},
+			member{"expression", e.node(node.Expression)})
+	case *BlockStatement:
+		return kind("BlockStatement",
+			member{"token", e.token(node.Token)},
+			member{"statements", e.statements(node.Statements)},
+			member{"rbrace", e.token(node.Rbrace)})
+	case *Identifier:
+		return kind("Identifier",
+			member{"token", e.token(node.Token)},
+			member{"value", node.Value})
+	case *IntegerLiteral:
+		value := json.Number(strconv.FormatInt(node.Value, 10))
+		if node.Big != nil {
+			value = json.Number(node.Big.String())
+		}
+		return kind("IntegerLiteral",
+			member{"token", e.token(node.Token)},
+			member{"value", value})
+	case *FloatLiteral:
+		return kind("FloatLiteral",
+			member{"token", e.token(node.Token)},
+			member{"value", node.Value})
+	case *StringLiteral:
+		return kind("StringLiteral",
+			member{"token", e.token(node.Token)},
+			member{"value", node.Value})
+	case *InterpolatedString:
+		return kind("InterpolatedString",
+			member{"token", e.token(node.Token)},
+			member{"parts", e.expressions(node.Parts)})
+	case *Boolean:
+		return kind("Boolean",
+			member{"token", e.token(node.Token)},
+			member{"value", node.Value})
+	case *PrefixExpression:
+		return kind("PrefixExpression",
+			member{"token", e.token(node.Token)},
+			member{"operator", node.Operator},
+			member{"right", e.node(node.Right)})
+	case *InfixExpression:
+		return kind("InfixExpression",
+			member{"token", e.token(node.Token)},
+			member{"operator", node.Operator},
+			member{"left", e.node(node.Left)},
+			member{"right", e.node(node.Right)})
+	case *AssignExpression:
+		return kind("AssignExpression",
+			member{"token", e.token(node.Token)},
+			member{"operator", node.Operator},
+			member{"target", e.node(node.Target)},
+			member{"value", e.node(node.Value)})
+	case *IfExpression:
+		return kind("IfExpression",
+			member{"token", e.token(node.Token)},
+			member{"condition", e.node(node.Condition)},
+			member{"consequence", e.block(node.Consequence)},
+			member{"alternative", e.block(node.Alternative)})
+	case *TryExpression:
+		return kind("TryExpression",
+			member{"token", e.token(node.Token)},
+			member{"block", e.block(node.Block)},
+			member{"parameter", e.identifier(node.Parameter)},
+			member{"catch", e.block(node.Catch)},
+			member{"finally", e.block(node.Finally)})
+	case *FunctionLiteral:
+		return kind("FunctionLiteral",
+			member{"token", e.token(node.Token)},
+			member{"name", node.Name},
+			member{"parameters", e.identifiers(node.Parameters)},
+			member{"defaults", e.expressions(node.Defaults)},
+			member{"variadic", node.Variadic},
+			member{"body", e.block(node.Body)})
+	case *CallExpression:
+		return kind("CallExpression",
+			member{"token", e.token(node.Token)},
+			member{"function", e.node(node.Function)},
+			member{"arguments", e.expressions(node.Arguments)},
+			member{"rparen", e.token(node.Rparen)})
+	case *SpreadExpression:
+		return kind("SpreadExpression",
+			member{"token", e.token(node.Token)},
+			member{"value", e.node(node.Value)})
+	case *ArrayLiteral:
+		return kind("ArrayLiteral",
+			member{"token", e.token(node.Token)},
+			member{"elements", e.expressions(node.Elements)},
+			member{"rbracket", e.token(node.Rbracket)})
+	case *IndexExpression:
+		return kind("IndexExpression",
+			member{"token", e.token(node.Token)},
+			member{"left", e.node(node.Left)},
+			member{"index", e.node(node.Index)},
+			member{"rbracket", e.token(node.Rbracket)})
+	case *SliceExpression:
+		return kind("SliceExpression",
+			member{"token", e.token(node.Token)},
+			member{"left", e.node(node.Left)},
+			member{"low", e.node(node.Low)},
+			member{"high", e.node(node.High)},
+			member{"rbracket", e.token(node.Rbracket)})
+	case *HashLiteral:
+		var pairs []interface{}
+		if node.Pairs != nil {
+			pairs = make([]interface{}, len(node.Pairs))
+			for i, pair := range node.Pairs {
+				pairs[i] = object{{"key", e.node(pair.Key)}, {"value", e.node(pair.Value)}}
+			}
+		}
+		return kind("HashLiteral",
+			member{"token", e.token(node.Token)},
+			member{"pairs", pairs},
+			member{"rbrace", e.token(node.Rbrace)})
+	default:
+		panic(fmt.Sprintf("ast: unexpected node type %T", node))
+	}
+}
+
+// fields holds the members of a JSON object before they are decoded. A
+// missing member is nil, which the decoder treats like null.
+type fields map[string]json.RawMessage
+
+// decoder keeps the first error it runs into and returns zero values from
+// then on, so that nodes can be put together without checking every field.
+type decoder struct {
+	filename string
+	err      error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("ast: "+format, a...)
+	}
+}
+
+func isNull(raw json.RawMessage) bool {
+	return raw == nil || string(raw) == "null"
+}
+
+func (d *decoder) value(raw json.RawMessage, v interface{}) {
+	if d.err != nil || isNull(raw) {
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.fail("%s", err)
+	}
+}
+
+func (d *decoder) string(raw json.RawMessage) string {
+	var s string
+	d.value(raw, &s)
+	return s
+}
+
+func (d *decoder) object(raw json.RawMessage) fields {
+	var f fields
+	d.value(raw, &f)
+	return f
+}
+
+func (d *decoder) list(raw json.RawMessage) []json.RawMessage {
+	var list []json.RawMessage
+	d.value(raw, &list)
+	return list
+}
+
+func (d *decoder) position(raw json.RawMessage) token.Position {
+	if isNull(raw) {
+		return token.Position{}
+	}
+	var pos struct{ Line, Column, Offset int }
+	d.value(raw, &pos)
+	return token.Position{Filename: d.filename, Line: pos.Line, Column: pos.Column, Offset: pos.Offset}
+}
+
+func (d *decoder) token(raw json.RawMessage) token.Token {
+	if isNull(raw) {
+		return token.Token{}
+	}
+	f := d.object(raw)
+	return token.Token{
+		Type:    token.TokenType(d.string(f["type"])),
+		Literal: d.string(f["literal"]),
+		Pos:     d.position(f["pos"]),
+		End:     d.position(f["end"]),
+	}
+}
+
+func (d *decoder) statements(raw json.RawMessage) []Statement {
+	if isNull(raw) {
+		return nil
+	}
+	list := d.list(raw)
+	statements := make([]Statement, len(list))
+	for i, item := range list {
+		if isNull(item) {
+			d.fail("expected a statement, got null")
+		}
+		statements[i] = d.statement(item)
+	}
+	return statements
+}
+
+func (d *decoder) expressions(raw json.RawMessage) []Expression {
+	return d.expressionList(raw, false)
+}
+
+// defaults decodes the default values of the parameters of a function, which
+// are null for the parameters without one.
+func (d *decoder) defaults(raw json.RawMessage) []Expression {
+	return d.expressionList(raw, true)
+}
+
+func (d *decoder) expressionList(raw json.RawMessage, nullable bool) []Expression {
+	if isNull(raw) {
+		return nil
+	}
+	list := d.list(raw)
+	expressions := make([]Expression, len(list))
+	for i, item := range list {
+		if !nullable && isNull(item) {
+			d.fail("expected an expression, got null")
+		}
+		expressions[i] = d.expression(item)
+	}
+	return expressions
+}
+
+func (d *decoder) identifiers(raw json.RawMessage) []*Identifier {
+	if isNull(raw) {
+		return nil
+	}
+	list := d.list(raw)
+	identifiers := make([]*Identifier, len(list))
+	for i, item := range list {
+		if isNull(item) {
+			d.fail("expected an Identifier, got null")
+		}
+		identifiers[i] = d.identifier(item)
+	}
+	return identifiers
+}
+
+func (d *decoder) comments(raw json.RawMessage) []*Comment {
+	if isNull(raw) {
+		return nil
+	}
+	list := d.list(raw)
+	comments := make([]*Comment, len(list))
+	for i, item := range list {
+		f := d.object(item)
+		if kind := d.string(f["kind"]); kind != "Comment" {
+			d.fail("expected a Comment, got %q", kind)
+		}
+		comments[i] = &Comment{Token: d.token(f["token"])}
+	}
+	return comments
+}
+
+func (d *decoder) statement(raw json.RawMessage) Statement {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+	statement, ok := node.(Statement)
+	if !ok {
+		d.fail("expected a statement, got %T", node)
+	}
+	return statement
+}
+
+func (d *decoder) expression(raw json.RawMessage) Expression {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+	expression, ok := node.(Expression)
+	if !ok {
+		d.fail("expected an expression, got %T", node)
+	}
+	return expression
+}
+
+func (d *decoder) identifier(raw json.RawMessage) *Identifier {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+	id, ok := node.(*Identifier)
+	if !ok {
+		d.fail("expected an Identifier, got %T", node)
+	}
+	return id
+}
+
+func (d *decoder) block(raw json.RawMessage) *BlockStatement {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		d.fail("expected a BlockStatement, got %T", node)
+	}
+	return block
+}
+
+func (d *decoder) node(raw json.RawMessage) Node {
+	if d.err != nil || isNull(raw) {
+		return nil
+	}
+	f := d.object(raw)
+	kind := d.string(f["kind"])
+	node := d.decodeNode(kind, f)
+	if d.err == nil {
+		d.checkChildren(kind, node)
+	}
+	return node
+}
+
+func (d *decoder) decodeNode(kind string, f fields) Node {
+	switch kind {
+	case "LetStatement":
+		return &LetStatement{Token: d.token(f["token"]), Name: d.identifier(f["name"]), Value: d.expression(f["value"])}
+	case "ReturnStatement":
+		return &ReturnStatement{Token: d.token(f["token"]), ReturnValue: d.expression(f["returnValue"])}
+	case "ThrowStatement":
+		return &ThrowStatement{Token: d.token(f["token"]), Value: d.expression(f["value"])}
+	case "WhileStatement":
+		return &WhileStatement{Token: d.token(f["token"]), Condition: d.expression(f["condition"]), Body: d.block(f["body"])}
+	case "ForStatement":
+		return &ForStatement{
+			Token:    d.token(f["token"]),
+			Variable: d.identifier(f["variable"]),
+			Iterable: d.expression(f["iterable"]),
+			Body:     d.block(f["body"]),
+		}
+	case "BreakStatement":
+		return &BreakStatement{Token: d.token(f["token"])}
+	case "ContinueStatement":
+		return &ContinueStatement{Token: d.token(f["token"])}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: d.token(f["token"]), Expression: d.expression(f["expression"])}
+	case "BlockStatement":
+		return &BlockStatement{Token: d.token(f["token"]), Statements: d.statements(f["statements"]), Rbrace: d.token(f["rbrace"])}
+	case "Identifier":
+		return &Identifier{Token: d.token(f["token"]), Value: d.string(f["value"])}
+	case "IntegerLiteral":
+		lit := &IntegerLiteral{Token: d.token(f["token"])}
+		if isNull(f["value"]) {
+			d.fail("IntegerLiteral without value")
+			return lit
+		}
+		var number json.Number
+		d.value(f["value"], &number)
+		if value, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+			lit.Value = value
+		} else if value, ok := new(big.Int).SetString(string(number), 10); ok {
+			lit.Big = value
+		} else {
+			d.fail("invalid integer %s", f["value"])
+		}
+		return lit
+	case "FloatLiteral":
+		lit := &FloatLiteral{Token: d.token(f["token"])}
+		d.value(f["value"], &lit.Value)
+		return lit
+	case "StringLiteral":
+		return &StringLiteral{Token: d.token(f["token"]), Value: d.string(f["value"])}
+	case "InterpolatedString":
+		return &InterpolatedString{Token: d.token(f["token"]), Parts: d.expressions(f["parts"])}
+	case "Boolean":
+		lit := &Boolean{Token: d.token(f["token"])}
+		d.value(f["value"], &lit.Value)
+		return lit
+	case "PrefixExpression":
+		return &PrefixExpression{Token: d.token(f["token"]), Operator: d.string(f["operator"]), Right: d.expression(f["right"])}
+	case "InfixExpression":
+		return &InfixExpression{
+			Token:    d.token(f["token"]),
+			Operator: d.string(f["operator"]),
+			Left:     d.expression(f["left"]),
+			Right:    d.expression(f["right"]),
+		}
+	case "AssignExpression":
+		return &AssignExpression{
+			Token:    d.token(f["token"]),
+			Operator: d.string(f["operator"]),
+			Target:   d.expression(f["target"]),
+			Value:    d.expression(f["value"]),
+		}
+	case "IfExpression":
+		return &IfExpression{
+			Token:       d.token(f["token"]),
+			Condition:   d.expression(f["condition"]),
+			Consequence: d.block(f["consequence"]),
+			Alternative: d.block(f["alternative"]),
+		}
+	case "TryExpression":
+		return &TryExpression{
+			Token:     d.token(f["token"]),
+			Block:     d.block(f["block"]),
+			Parameter: d.identifier(f["parameter"]),
+			Catch:     d.block(f["catch"]),
+			Finally:   d.block(f["finally"]),
+		}
+	case "FunctionLiteral":
+		lit := &FunctionLiteral{
+			Token:      d.token(f["token"]),
+			Name:       d.string(f["name"]),
+			Parameters: d.identifiers(f["parameters"]),
+			Defaults:   d.defaults(f["defaults"]),
+			Body:       d.block(f["body"]),
+		}
+		d.value(f["variadic"], &lit.Variadic)
+		return lit
+	case "CallExpression":
+		return &CallExpression{
+			Token:     d.token(f["token"]),
+			Function:  d.expression(f["function"]),
+			Arguments: d.expressions(f["arguments"]),
+			Rparen:    d.token(f["rparen"]),
+		}
+	case "SpreadExpression":
+		return &SpreadExpression{Token: d.token(f["token"]), Value: d.expression(f["value"])}
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: d.token(f["token"]), Elements: d.expressions(f["elements"]), Rbracket: d.token(f["rbracket"])}
+	case "IndexExpression":
+		return &IndexExpression{
+			Token:    d.token(f["token"]),
+			Left:     d.expression(f["left"]),
+			Index:    d.expression(f["index"]),
+			Rbracket: d.token(f["rbracket"]),
+		}
+	case "SliceExpression":
+		return &SliceExpression{
+			Token:    d.token(f["token"]),
+			Left:     d.expression(f["left"]),
+			Low:      d.expression(f["low"]),
+			High:     d.expression(f["high"]),
+			Rbracket: d.token(f["rbracket"]),
+		}
+	case "HashLiteral":
+		hash := &HashLiteral{Token: d.token(f["token"]), Rbrace: d.token(f["rbrace"])}
+		if pairs := f["pairs"]; !isNull(pairs) {
+			hash.Pairs = []HashPair{}
+			for _, item := range d.list(pairs) {
+				pair := d.object(item)
+				hash.Pairs = append(hash.Pairs, HashPair{Key: d.expression(pair["key"]), Value: d.expression(pair["value"])})
+				if d.err == nil && (isNull(pair["key"]) || isNull(pair["value"])) {
+					d.fail("expected an expression, got null")
+				}
+			}
+		}
+		return hash
+	default:
+		d.fail("unknown node kind %q", kind)
+		return nil
+	}
+}
+
+// checkChildren fails if node lacks a child that the parser always sets, so
+// that the engines only get trees the parser could have produced.
+func (d *decoder) checkChildren(kind string, node Node) {
+	require := func(field string, present bool) {
+		if !present {
+			d.fail("%s without %s", kind, field)
+		}
+	}
+
+	switch node := node.(type) {
+	case *LetStatement:
+		require("name", node.Name != nil)
+		require("value", node.Value != nil)
+	case *ReturnStatement:
+		require("returnValue", node.ReturnValue != nil)
+	case *ThrowStatement:
+		require("value", node.Value != nil)
+	case *WhileStatement:
+		require("condition", node.Condition != nil)
+		require("body", node.Body != nil)
+	case *ForStatement:
+		require("variable", node.Variable != nil)
+		require("iterable", node.Iterable != nil)
+		require("body", node.Body != nil)
+	case *ExpressionStatement:
+		require("expression", node.Expression != nil)
+	case *PrefixExpression:
+		require("right", node.Right != nil)
+	case *InfixExpression:
+		require("left", node.Left != nil)
+		require("right", node.Right != nil)
+	case *AssignExpression:
+		require("target", node.Target != nil)
+		require("value", node.Value != nil)
+	case *IfExpression:
+		require("condition", node.Condition != nil)
+		require("consequence", node.Consequence != nil)
+	case *TryExpression:
+		require("block", node.Block != nil)
+		require("catch or finally", node.Catch != nil || node.Finally != nil)
+		require("parameter", node.Catch == nil || node.Parameter != nil)
+		require("catch", node.Parameter == nil || node.Catch != nil)
+	case *FunctionLiteral:
+		require("body", node.Body != nil)
+		if len(node.Defaults) > len(node.Parameters) {
+			d.fail("%s with more defaults than parameters", kind)
+		}
+		if node.Variadic && len(node.Parameters) == 0 {
+			d.fail("variadic %s without parameters", kind)
+		}
+	case *CallExpression:
+		require("function", node.Function != nil)
+	case *SpreadExpression:
+		require("value", node.Value != nil)
+	case *IndexExpression:
+		require("left", node.Left != nil)
+		require("index", node.Index != nil)
+	case *SliceExpression:
+		require("left", node.Left != nil)
+	}
+}
@@ -0,0 +1,151 @@
+package ast_test
+
+import (
+	"ast"
+	"encoding/json"
+	"lexer"
+	"parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"let x = 5; const y = x;",
+		"return 1 + 2 * 3; throw \"oops\";",
+		"while (x < 10) { x += 1; if (x == 5) { break } else { continue } }",
+		"for (item in [1, 2, 3]) { puts(item) }",
+		"let add = fn(a, b = 2, ...rest) { a + b }; add(...[1, 2])",
+		"fn() {}",
+		"try { throw 1 } catch (e) { e } finally { 2 }",
+		"try { 1 } finally { 2 }",
+		"-a; !true; false",
+		"x = y[0] = z",
+		"a[1:2]; a[:2]; a[1:]; a[:]",
+		"{\"a\": 1, 2: [3.5, 99999999999999999999]}; {}",
+		"\"text ${name} and ${1 + 2}\"",
+		"#!/usr/bin/env monkey run\n// about x\nlet x = 1; /* done */",
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			l := lexer.NewWithFilename("test.mk", input)
+			l.EmitComments()
+			p := parser.New(l)
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+
+			data, err := json.Marshal(program)
+			if err != nil {
+				t.Fatalf("json.Marshal failed: %s", err)
+			}
+
+			decoded := &ast.Program{}
+			if err := json.Unmarshal(data, decoded); err != nil {
+				t.Fatalf("json.Unmarshal failed: %s\n%s", err, data)
+			}
+			if !reflect.DeepEqual(program, decoded) {
+				t.Fatalf("program changed by the round trip.\nbefore=%q\nafter= %q\n%s",
+					program.String(), decoded.String(), data)
+			}
+
+			again, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatalf("json.Marshal failed: %s", err)
+			}
+			if string(again) != string(data) {
+				t.Fatalf("JSON changed by the round trip.\nbefore=%s\nafter= %s", data, again)
+			}
+		})
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	program := parser.New(lexer.NewWithFilename("test.mk", "-x")).ParseProgram()
+
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %s", err)
+	}
+
+	expected := `{"kind":"Program","filename":"test.mk",` +
+		`"pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":3,"offset":2},` +
+		`"statements":[{"kind":"ExpressionStatement",` +
+		`"pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":3,"offset":2},` +
+		`"token":{"type":"-","literal":"-","pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":2,"offset":1}},` +
+		`"expression":{"kind":"PrefixExpression",` +
+		`"pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":3,"offset":2},` +
+		`"token":{"type":"-","literal":"-","pos":{"line":1,"column":1,"offset":0},"end":{"line":1,"column":2,"offset":1}},` +
+		`"operator":"-",` +
+		`"right":{"kind":"Identifier",` +
+		`"pos":{"line":1,"column":2,"offset":1},"end":{"line":1,"column":3,"offset":2},` +
+		`"token":{"type":"IDENT","literal":"x","pos":{"line":1,"column":2,"offset":1},"end":{"line":1,"column":3,"offset":2}},` +
+		`"value":"x"}}}],` +
+		`"comments":null}`
+	if string(data) != expected {
+		t.Fatalf("JSON wrong.\nexpected=%s\ngot=     %s", expected, data)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "cannot unmarshal array"},
+		{`{"kind": "Identifier"}`, `ast: expected a Program, got "Identifier"`},
+		{`{"kind": "Program", "statements": [{"kind": "Loop"}]}`, `ast: unknown node kind "Loop"`},
+		{`{"kind": "Program", "statements": [{"kind": "Identifier"}]}`, "ast: expected a statement, got *ast.Identifier"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "BreakStatement"}}]}`,
+			"ast: expected an expression, got *ast.BreakStatement"},
+		{`{"kind": "Program", "statements": [{"kind": "ForStatement", "variable": {"kind": "Boolean"}}]}`,
+			"ast: expected an Identifier, got *ast.Boolean"},
+		{`{"kind": "Program", "statements": [{"kind": "WhileStatement", "body": {"kind": "HashLiteral"}}]}`,
+			"ast: expected a BlockStatement, got *ast.HashLiteral"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "IntegerLiteral", "value": 1.5}}]}`,
+			"ast: invalid integer 1.5"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "IntegerLiteral", "value": "1e5"}}]}`,
+			`ast: invalid integer "1e5"`},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "IntegerLiteral"}}]}`,
+			"ast: IntegerLiteral without value"},
+		// trees the parser cannot produce
+		{`{"kind": "Program", "statements": [null]}`, "ast: expected a statement, got null"},
+		{`{"kind": "Program", "statements": [{"kind": "LetStatement"}]}`, "ast: LetStatement without name"},
+		{`{"kind": "Program", "statements": [{"kind": "LetStatement", "name": {"kind": "Identifier", "value": "x"}}]}`,
+			"ast: LetStatement without value"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement"}]}`, "ast: ExpressionStatement without expression"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "InfixExpression", "operator": "+"}}]}`,
+			"ast: InfixExpression without left"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "IfExpression"}}]}`,
+			"ast: IfExpression without condition"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "IfExpression", "condition": {"kind": "Boolean", "value": true}}}]}`,
+			"ast: IfExpression without consequence"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "FunctionLiteral", "parameters": [null]}}]}`,
+			"ast: expected an Identifier, got null"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "FunctionLiteral"}}]}`,
+			"ast: FunctionLiteral without body"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "ArrayLiteral", "elements": [null]}}]}`,
+			"ast: expected an expression, got null"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "HashLiteral", "pairs": [{"key": null}]}}]}`,
+			"ast: expected an expression, got null"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "expression": {"kind": "TryExpression", "block": {"kind": "BlockStatement"}}}]}`,
+			"ast: TryExpression without catch or finally"},
+		{`{"kind": "Program", "statements": [{"kind": "ExpressionStatement", "token": {"type": 1}}]}`,
+			"ast: json: cannot unmarshal number"},
+	}
+
+	for _, tt := range tests {
+		err := json.Unmarshal([]byte(tt.input), &ast.Program{})
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: error wrong. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
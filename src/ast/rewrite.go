@@ -0,0 +1,394 @@
+package ast
+
+import "fmt"
+
+// An ApplyFunc is invoked by Apply for each node, before and after its
+// children, with a Cursor describing the node and providing operations on it.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree in the order of Walk and returns it, possibly
+// modified.
+//
+// For each node it calls pre, if not nil, then traverses the children of the
+// node, then calls post, if not nil. If pre returns false the children and
+// post are skipped. If post returns false, the traversal ends and Apply
+// returns immediately.
+//
+// The node may be replaced, deleted or have nodes inserted next to it through
+// the Cursor. After pre replaces a node, the children of the new node are
+// traversed; nodes inserted before or after the current one are not.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	if root == nil {
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = root
+	}()
+
+	a := &application{pre: pre, post: post}
+	a.visit(&Cursor{index: -1, node: root, set: func(n Node) { root = n }})
+	return root
+}
+
+var abort = new(int) // a panic value that ends Apply early
+
+// A Cursor describes a node encountered during Apply. The Cursor is only
+// valid during the call of the ApplyFunc it was passed to.
+type Cursor struct {
+	parent Node
+	name   string
+	index  int // in the hash pairs or the parameters of the parent, -1 otherwise
+	node   Node
+	set    func(Node) // replaces the node in the parent, nil if it is in a list
+	list   list       // the list holding the node, nil if it is not in one
+	iter   *iterator
+}
+
+// Node returns the current node, or nil if it was deleted.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node, nil for the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the field of the parent holding the current node,
+// like "Condition" or "Statements". The key and the value of a hash pair are
+// named "Key" and "Value".
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the current node in the list holding it, the
+// index of its hash pair for keys and values, and the index of the parameter
+// for default values. It returns -1 otherwise.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return c.index
+}
+
+// Replace replaces the current node with n. It panics if n cannot take the
+// place of the node, like an expression in a list of statements.
+func (c *Cursor) Replace(n Node) {
+	if c.list != nil {
+		c.list.set(c.iter.index, n)
+	} else {
+		c.set(n)
+	}
+	c.node = n
+}
+
+// Delete removes the current node from the list holding it, and panics if
+// the node is not in a list. Deleting a parameter of a function literal also
+// deletes its default value.
+func (c *Cursor) Delete() {
+	if c.list == nil {
+		panic("ast: Delete of a node not contained in a list")
+	}
+	c.list.delete(c.iter.index)
+	c.iter.step--
+	c.node = nil
+}
+
+// InsertAfter inserts n after the current node in the list holding it, and
+// panics if the node is not in a list. Apply does not traverse n.
+func (c *Cursor) InsertAfter(n Node) {
+	if c.list == nil {
+		panic("ast: InsertAfter of a node not contained in a list")
+	}
+	c.list.insert(c.iter.index+1, n)
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current node in the list holding it, and
+// panics if the node is not in a list. Apply does not traverse n.
+func (c *Cursor) InsertBefore(n Node) {
+	if c.list == nil {
+		panic("ast: InsertBefore of a node not contained in a list")
+	}
+	c.list.insert(c.iter.index, n)
+	c.iter.index++
+}
+
+// iterator is the position of Apply in a list, which moves by step once the
+// current node is done.
+type iterator struct {
+	index, step int
+}
+
+type application struct {
+	pre, post ApplyFunc
+}
+
+func (a *application) visit(c *Cursor) {
+	if a.pre != nil && !a.pre(c) {
+		return
+	}
+	if c.node != nil {
+		a.children(c.node)
+	}
+	if a.post != nil && !a.post(c) {
+		panic(abort)
+	}
+}
+
+// expression, identifier and block visit a child held in a field of parent,
+// skipping missing ones, and replace it with set.
+func (a *application) expression(parent Node, name string, x Expression, set func(Expression)) {
+	if x != nil {
+		a.visit(&Cursor{parent: parent, name: name, index: -1, node: x, set: func(n Node) { set(toExpression(n)) }})
+	}
+}
+
+func (a *application) identifier(parent Node, name string, id *Identifier, set func(*Identifier)) {
+	if id != nil {
+		a.visit(&Cursor{parent: parent, name: name, index: -1, node: id, set: func(n Node) { set(toIdentifier(n)) }})
+	}
+}
+
+func (a *application) block(parent Node, name string, block *BlockStatement, set func(*BlockStatement)) {
+	if block != nil {
+		a.visit(&Cursor{parent: parent, name: name, index: -1, node: block, set: func(n Node) { set(toBlock(n)) }})
+	}
+}
+
+// list visits the nodes of l in turn, calling after, if not nil, with the
+// index of each node that was not deleted.
+func (a *application) list(parent Node, name string, l list, after func(i int)) {
+	iter := &iterator{}
+	for iter.index = 0; iter.index < l.len(); iter.index += iter.step {
+		iter.step = 1
+		if n := l.at(iter.index); n != nil {
+			a.visit(&Cursor{parent: parent, name: name, node: n, list: l, iter: iter})
+		}
+		if after != nil && iter.step > 0 {
+			after(iter.index)
+		}
+	}
+}
+
+func (a *application) children(node Node) {
+	switch n := node.(type) {
+	case *Program:
+		a.list(n, "Statements", statementList{&n.Statements}, nil)
+	case *LetStatement:
+		a.identifier(n, "Name", n.Name, func(id *Identifier) { n.Name = id })
+		a.expression(n, "Value", n.Value, func(x Expression) { n.Value = x })
+	case *ReturnStatement:
+		a.expression(n, "ReturnValue", n.ReturnValue, func(x Expression) { n.ReturnValue = x })
+	case *ThrowStatement:
+		a.expression(n, "Value", n.Value, func(x Expression) { n.Value = x })
+	case *WhileStatement:
+		a.expression(n, "Condition", n.Condition, func(x Expression) { n.Condition = x })
+		a.block(n, "Body", n.Body, func(b *BlockStatement) { n.Body = b })
+	case *ForStatement:
+		a.identifier(n, "Variable", n.Variable, func(id *Identifier) { n.Variable = id })
+		a.expression(n, "Iterable", n.Iterable, func(x Expression) { n.Iterable = x })
+		a.block(n, "Body", n.Body, func(b *BlockStatement) { n.Body = b })
+	case *BreakStatement, *ContinueStatement:
+		// nothing to do
+	case *ExpressionStatement:
+		a.expression(n, "Expression", n.Expression, func(x Expression) { n.Expression = x })
+	case *BlockStatement:
+		a.list(n, "Statements", statementList{&n.Statements}, nil)
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean:
+		// nothing to do
+	case *InterpolatedString:
+		a.list(n, "Parts", expressionList{&n.Parts}, nil)
+	case *PrefixExpression:
+		a.expression(n, "Right", n.Right, func(x Expression) { n.Right = x })
+	case *InfixExpression:
+		a.expression(n, "Left", n.Left, func(x Expression) { n.Left = x })
+		a.expression(n, "Right", n.Right, func(x Expression) { n.Right = x })
+	case *AssignExpression:
+		a.expression(n, "Target", n.Target, func(x Expression) { n.Target = x })
+		a.expression(n, "Value", n.Value, func(x Expression) { n.Value = x })
+	case *IfExpression:
+		a.expression(n, "Condition", n.Condition, func(x Expression) { n.Condition = x })
+		a.block(n, "Consequence", n.Consequence, func(b *BlockStatement) { n.Consequence = b })
+		a.block(n, "Alternative", n.Alternative, func(b *BlockStatement) { n.Alternative = b })
+	case *TryExpression:
+		a.block(n, "Block", n.Block, func(b *BlockStatement) { n.Block = b })
+		a.identifier(n, "Parameter", n.Parameter, func(id *Identifier) { n.Parameter = id })
+		a.block(n, "Catch", n.Catch, func(b *BlockStatement) { n.Catch = b })
+		a.block(n, "Finally", n.Finally, func(b *BlockStatement) { n.Finally = b })
+	case *FunctionLiteral:
+		a.list(n, "Parameters", parameterList{n}, func(i int) {
+			if value := n.Default(i); value != nil {
+				a.visit(&Cursor{parent: n, name: "Defaults", index: i, node: value,
+					set: func(x Node) { n.Defaults[i] = toExpression(x) }})
+			}
+		})
+		a.block(n, "Body", n.Body, func(b *BlockStatement) { n.Body = b })
+	case *CallExpression:
+		a.expression(n, "Function", n.Function, func(x Expression) { n.Function = x })
+		a.list(n, "Arguments", expressionList{&n.Arguments}, nil)
+	case *SpreadExpression:
+		a.expression(n, "Value", n.Value, func(x Expression) { n.Value = x })
+	case *ArrayLiteral:
+		a.list(n, "Elements", expressionList{&n.Elements}, nil)
+	case *IndexExpression:
+		a.expression(n, "Left", n.Left, func(x Expression) { n.Left = x })
+		a.expression(n, "Index", n.Index, func(x Expression) { n.Index = x })
+	case *SliceExpression:
+		a.expression(n, "Left", n.Left, func(x Expression) { n.Left = x })
+		a.expression(n, "Low", n.Low, func(x Expression) { n.Low = x })
+		a.expression(n, "High", n.High, func(x Expression) { n.High = x })
+	case *HashLiteral:
+		for i := range n.Pairs {
+			pair := &n.Pairs[i]
+			if pair.Key != nil {
+				a.visit(&Cursor{parent: n, name: "Key", index: i, node: pair.Key,
+					set: func(x Node) { pair.Key = toExpression(x) }})
+			}
+			if pair.Value != nil {
+				a.visit(&Cursor{parent: n, name: "Value", index: i, node: pair.Value,
+					set: func(x Node) { pair.Value = toExpression(x) }})
+			}
+		}
+	default:
+		panic(fmt.Sprintf("ast.Apply: unexpected node type %T", n))
+	}
+}
+
+// list is a list of nodes held by a parent, which a Cursor can edit.
+type list interface {
+	len() int
+	at(i int) Node // nil for a missing node
+	set(i int, n Node)
+	insert(i int, n Node)
+	delete(i int)
+}
+
+type statementList struct {
+	s *[]Statement
+}
+
+func (l statementList) len() int { return len(*l.s) }
+
+func (l statementList) at(i int) Node {
+	if (*l.s)[i] == nil {
+		return nil
+	}
+	return (*l.s)[i]
+}
+
+func (l statementList) set(i int, n Node) { (*l.s)[i] = toStatement(n) }
+
+func (l statementList) insert(i int, n Node) {
+	statement := toStatement(n)
+	*l.s = append(*l.s, nil)
+	copy((*l.s)[i+1:], (*l.s)[i:])
+	(*l.s)[i] = statement
+}
+
+func (l statementList) delete(i int) { *l.s = append((*l.s)[:i], (*l.s)[i+1:]...) }
+
+type expressionList struct {
+	s *[]Expression
+}
+
+func (l expressionList) len() int { return len(*l.s) }
+
+func (l expressionList) at(i int) Node {
+	if (*l.s)[i] == nil {
+		return nil
+	}
+	return (*l.s)[i]
+}
+
+func (l expressionList) set(i int, n Node) { (*l.s)[i] = toExpression(n) }
+
+func (l expressionList) insert(i int, n Node) {
+	expression := toExpression(n)
+	*l.s = append(*l.s, nil)
+	copy((*l.s)[i+1:], (*l.s)[i:])
+	(*l.s)[i] = expression
+}
+
+func (l expressionList) delete(i int) { *l.s = append((*l.s)[:i], (*l.s)[i+1:]...) }
+
+// parameterList is the parameters of a function literal. Inserting and
+// deleting parameters keeps the default values lined up with them; inserted
+// parameters have none.
+type parameterList struct {
+	fl *FunctionLiteral
+}
+
+func (l parameterList) len() int { return len(l.fl.Parameters) }
+
+func (l parameterList) at(i int) Node {
+	if l.fl.Parameters[i] == nil {
+		return nil
+	}
+	return l.fl.Parameters[i]
+}
+
+func (l parameterList) set(i int, n Node) { l.fl.Parameters[i] = toIdentifier(n) }
+
+func (l parameterList) insert(i int, n Node) {
+	id := toIdentifier(n)
+	l.fl.Parameters = append(l.fl.Parameters, nil)
+	copy(l.fl.Parameters[i+1:], l.fl.Parameters[i:])
+	l.fl.Parameters[i] = id
+	if i < len(l.fl.Defaults) {
+		l.fl.Defaults = append(l.fl.Defaults, nil)
+		copy(l.fl.Defaults[i+1:], l.fl.Defaults[i:])
+		l.fl.Defaults[i] = nil
+	}
+}
+
+func (l parameterList) delete(i int) {
+	l.fl.Parameters = append(l.fl.Parameters[:i], l.fl.Parameters[i+1:]...)
+	if i < len(l.fl.Defaults) {
+		l.fl.Defaults = append(l.fl.Defaults[:i], l.fl.Defaults[i+1:]...)
+	}
+}
+
+// The conversions below panic when a node is put where it does not belong.
+
+func toStatement(n Node) Statement {
+	if n == nil {
+		return nil
+	}
+	statement, ok := n.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast: %T is not a statement", n))
+	}
+	return statement
+}
+
+func toExpression(n Node) Expression {
+	if n == nil {
+		return nil
+	}
+	expression, ok := n.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast: %T is not an expression", n))
+	}
+	return expression
+}
+
+func toIdentifier(n Node) *Identifier {
+	if n == nil {
+		return nil
+	}
+	id, ok := n.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast: %T is not an identifier", n))
+	}
+	return id
+}
+
+func toBlock(n Node) *BlockStatement {
+	if n == nil {
+		return nil
+	}
+	block, ok := n.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast: %T is not a block", n))
+	}
+	return block
+}
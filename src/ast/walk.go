@@ -0,0 +1,151 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order, visiting the children of
+// a node in the order they appear in the source: it starts by calling
+// v.Visit(node), which must not be nil. Missing children, like the else
+// branch of an if without one, are skipped.
+//
+// The parameters of a function literal are visited one after the other, each
+// followed by its default value if it has one. The key and the value of each
+// pair of a hash literal are visited in turn.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ThrowStatement:
+		walkExpression(v, n.Value)
+	case *WhileStatement:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Body)
+	case *ForStatement:
+		walkIdentifier(v, n.Variable)
+		walkExpression(v, n.Iterable)
+		walkBlock(v, n.Body)
+	case *BreakStatement, *ContinueStatement:
+		// nothing to do
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean:
+		// nothing to do
+	case *InterpolatedString:
+		walkExpressions(v, n.Parts)
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *AssignExpression:
+		walkExpression(v, n.Target)
+		walkExpression(v, n.Value)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		walkBlock(v, n.Alternative)
+	case *TryExpression:
+		walkBlock(v, n.Block)
+		walkIdentifier(v, n.Parameter)
+		walkBlock(v, n.Catch)
+		walkBlock(v, n.Finally)
+	case *FunctionLiteral:
+		for i, parameter := range n.Parameters {
+			walkIdentifier(v, parameter)
+			walkExpression(v, n.Default(i))
+		}
+		walkBlock(v, n.Body)
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *SpreadExpression:
+		walkExpression(v, n.Value)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *SliceExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Low)
+		walkExpression(v, n.High)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// The helpers below skip missing children, which for the pointer fields means
+// not turning a nil pointer into a non-nil Node.
+
+func walkIdentifier(v Visitor, id *Identifier) {
+	if id != nil {
+		Walk(v, id)
+	}
+}
+
+func walkBlock(v Visitor, block *BlockStatement) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
+
+func walkExpression(v Visitor, expression Expression) {
+	if expression != nil {
+		Walk(v, expression)
+	}
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, statement := range statements {
+		if statement != nil {
+			Walk(v, statement)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, expressions []Expression) {
+	for _, expression := range expressions {
+		walkExpression(v, expression)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in the order of Walk: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the children of node, followed by a call of
+// f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
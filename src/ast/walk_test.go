@@ -0,0 +1,485 @@
+package ast_test
+
+import (
+	"ast"
+	"fmt"
+	"lexer"
+	"parser"
+	"reflect"
+	"strings"
+	"testing"
+	"token"
+)
+
+// everything has every kind of node, so that the tests below see them all.
+const everything = `
+let f = fn(a, b = 2, ...rest) { return a + b; };
+const h = {"k": [1, 2.5, true][0], "s": "x${a}y"};
+while (!done) { break; }
+for (item in xs[1:]) { continue; }
+try { throw f(...args) } catch (e) { e } finally { x = 1 }
+if (c) { 1 } else { {} }
+`
+
+// describe names the node and its literal, like "Identifier a".
+func describe(node ast.Node) string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	switch node.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean,
+		*ast.PrefixExpression, *ast.InfixExpression, *ast.AssignExpression:
+		return kind + " " + node.TokenLiteral()
+	}
+	return kind
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestInspectOrder(t *testing.T) {
+	program := parse(t, everything)
+
+	var visited []string
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		visited = append(visited, strings.Repeat(". ", depth)+describe(node))
+		depth++
+		return true
+	})
+
+	expected := []string{
+		"Program",
+		". LetStatement",
+		". . Identifier f",
+		". . FunctionLiteral",
+		". . . Identifier a",
+		". . . Identifier b",
+		". . . IntegerLiteral 2",
+		". . . Identifier rest",
+		". . . BlockStatement",
+		". . . . ReturnStatement",
+		". . . . . InfixExpression +",
+		". . . . . . Identifier a",
+		". . . . . . Identifier b",
+		". LetStatement",
+		". . Identifier h",
+		". . HashLiteral",
+		". . . StringLiteral k",
+		". . . IndexExpression",
+		". . . . ArrayLiteral",
+		". . . . . IntegerLiteral 1",
+		". . . . . FloatLiteral 2.5",
+		". . . . . Boolean true",
+		". . . . IntegerLiteral 0",
+		". . . StringLiteral s",
+		". . . InterpolatedString",
+		". . . . StringLiteral x",
+		". . . . Identifier a",
+		". . . . StringLiteral y",
+		". WhileStatement",
+		". . PrefixExpression !",
+		". . . Identifier done",
+		". . BlockStatement",
+		". . . BreakStatement",
+		". ForStatement",
+		". . Identifier item",
+		". . SliceExpression",
+		". . . Identifier xs",
+		". . . IntegerLiteral 1",
+		". . BlockStatement",
+		". . . ContinueStatement",
+		". ExpressionStatement",
+		". . TryExpression",
+		". . . BlockStatement",
+		". . . . ThrowStatement",
+		". . . . . CallExpression",
+		". . . . . . Identifier f",
+		". . . . . . SpreadExpression",
+		". . . . . . . Identifier args",
+		". . . Identifier e",
+		". . . BlockStatement",
+		". . . . ExpressionStatement",
+		". . . . . Identifier e",
+		". . . BlockStatement",
+		". . . . ExpressionStatement",
+		". . . . . AssignExpression =",
+		". . . . . . Identifier x",
+		". . . . . . IntegerLiteral 1",
+		". ExpressionStatement",
+		". . IfExpression",
+		". . . Identifier c",
+		". . . BlockStatement",
+		". . . . ExpressionStatement",
+		". . . . . IntegerLiteral 1",
+		". . . BlockStatement",
+		". . . . ExpressionStatement",
+		". . . . . HashLiteral",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("traversal order wrong.\nexpected=\n%s\ngot=\n%s",
+			strings.Join(expected, "\n"), strings.Join(visited, "\n"))
+	}
+	if depth != 0 {
+		t.Fatalf("f(nil) not called once for each node visited. depth=%d", depth)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parse(t, "let f = fn(x) { x + 1 }; f(2)")
+
+	var visited []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		visited = append(visited, describe(node))
+		_, isFunction := node.(*ast.FunctionLiteral)
+		return !isFunction
+	})
+
+	expected := []string{"Program", "LetStatement", "Identifier f", "FunctionLiteral",
+		"ExpressionStatement", "CallExpression", "Identifier f", "IntegerLiteral 2"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("visited wrong.\nexpected=%v\ngot=     %v", expected, visited)
+	}
+}
+
+// recorder is a Visitor that records the nodes in the order they are done
+// with, after their children.
+type recorder struct {
+	stack []ast.Node
+	done  []string
+}
+
+func (r *recorder) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		r.done = append(r.done, describe(r.stack[len(r.stack)-1]))
+		r.stack = r.stack[:len(r.stack)-1]
+		return nil
+	}
+	r.stack = append(r.stack, node)
+	return r
+}
+
+func TestApplyOrder(t *testing.T) {
+	program := parse(t, everything)
+
+	var pre []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			pre = append(pre, describe(node))
+		}
+		return true
+	})
+	r := &recorder{}
+	ast.Walk(r, program)
+
+	var applyPre, applyPost []string
+	result := ast.Apply(program,
+		func(c *ast.Cursor) bool {
+			applyPre = append(applyPre, describe(c.Node()))
+			return true
+		},
+		func(c *ast.Cursor) bool {
+			applyPost = append(applyPost, describe(c.Node()))
+			return true
+		})
+
+	if result != program {
+		t.Fatalf("Apply returned %v, expected the program", result)
+	}
+	if !reflect.DeepEqual(applyPre, pre) {
+		t.Fatalf("Apply pre order differs from Inspect.\nInspect=%v\nApply=  %v", pre, applyPre)
+	}
+	if !reflect.DeepEqual(applyPost, r.done) {
+		t.Fatalf("Apply post order differs from Walk.\nWalk= %v\nApply=%v", r.done, applyPost)
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	program := parse(t, `let f = fn(a, b = 1) { {"k": a}[b:] }`)
+
+	var positions []string
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		parent := "<nil>"
+		if c.Parent() != nil {
+			parent = describe(c.Parent())
+		}
+		positions = append(positions, fmt.Sprintf("%s %s[%d] %s", parent, c.Name(), c.Index(), describe(c.Node())))
+		return true
+	}, nil)
+
+	expected := []string{
+		"<nil> [-1] Program",
+		"Program Statements[0] LetStatement",
+		"LetStatement Name[-1] Identifier f",
+		"LetStatement Value[-1] FunctionLiteral",
+		"FunctionLiteral Parameters[0] Identifier a",
+		"FunctionLiteral Parameters[1] Identifier b",
+		"FunctionLiteral Defaults[1] IntegerLiteral 1",
+		"FunctionLiteral Body[-1] BlockStatement",
+		"BlockStatement Statements[0] ExpressionStatement",
+		"ExpressionStatement Expression[-1] SliceExpression",
+		"SliceExpression Left[-1] HashLiteral",
+		"HashLiteral Key[0] StringLiteral k",
+		"HashLiteral Value[0] Identifier a",
+		"SliceExpression Low[-1] Identifier b",
+	}
+	if !reflect.DeepEqual(positions, expected) {
+		t.Fatalf("cursors wrong.\nexpected=\n%s\ngot=\n%s", strings.Join(expected, "\n"), strings.Join(positions, "\n"))
+	}
+}
+
+func identifier(name string) *ast.Identifier {
+	return &ast.Identifier{Value: name}
+}
+
+func TestApplyRewrite(t *testing.T) {
+	tests := []struct {
+		input    string
+		pre      ast.ApplyFunc
+		post     ast.ApplyFunc
+		expected string
+	}{
+		// renaming, including parameters, the variables of loops and catch
+		// and the keys of hashes
+		{
+			"let f = fn(x, y = x) { for (x in [x]) { try { x } catch (x) { {x: x} } } }",
+			func(c *ast.Cursor) bool {
+				if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "x" {
+					c.Replace(identifier("z"))
+				}
+				return true
+			},
+			nil,
+			"let f = fn(z,y = z) {for (z in [z]) {try {z;} catch (z) {{z: z};};};};",
+		},
+		// deleting statements and inserting new ones, which are not visited
+		{
+			"a; b; c; d",
+			func(c *ast.Cursor) bool {
+				switch node := c.Node().(type) {
+				case *ast.ExpressionStatement:
+					switch node.String() {
+					case "b":
+						c.Delete()
+					case "c":
+						c.InsertBefore(&ast.ExpressionStatement{Expression: identifier("b2")})
+						c.InsertAfter(&ast.ExpressionStatement{Expression: identifier("c2")})
+					}
+				case *ast.Identifier:
+					c.Replace(identifier(strings.ToUpper(node.Value)))
+				}
+				return true
+			},
+			nil,
+			"Ab2Cc2D",
+		},
+		// deleting elements from the middle and the end of lists
+		{
+			"[1, 2, 3, 4]; f(1, 2)",
+			func(c *ast.Cursor) bool {
+				if lit, ok := c.Node().(*ast.IntegerLiteral); ok && lit.Value%2 == 0 {
+					c.Delete()
+				}
+				return true
+			},
+			nil,
+			"[1, 3]f(1)",
+		},
+		// deleting a parameter deletes its default value too
+		{
+			"fn(a, b = 1, c = 2) { c }",
+			func(c *ast.Cursor) bool {
+				if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "b" && c.Name() == "Parameters" {
+					c.Delete()
+				}
+				return true
+			},
+			nil,
+			"fn(a,c = 2) {c;}",
+		},
+		// inserting a parameter gives it no default value
+		{
+			"fn(a = 1) { a }",
+			func(c *ast.Cursor) bool {
+				if c.Name() == "Parameters" {
+					c.InsertBefore(identifier("first"))
+				}
+				return true
+			},
+			nil,
+			"fn(first,a = 1) {a;}",
+		},
+		// replacing a node with a new one, whose children are visited
+		{
+			"-1",
+			func(c *ast.Cursor) bool {
+				switch node := c.Node().(type) {
+				case *ast.PrefixExpression:
+					c.Replace(&ast.InfixExpression{Operator: "-", Left: &ast.IntegerLiteral{Value: 0}, Right: node.Right})
+				case *ast.IntegerLiteral:
+					c.Replace(&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(node.Value + 10)}, Value: node.Value + 10})
+				}
+				return true
+			},
+			nil,
+			"(10 - 11)",
+		},
+		// folding constants bottom up in post
+		{
+			"let x = 1 + 2 * 3;",
+			nil,
+			func(c *ast.Cursor) bool {
+				infix, ok := c.Node().(*ast.InfixExpression)
+				if !ok {
+					return true
+				}
+				left, lok := infix.Left.(*ast.IntegerLiteral)
+				right, rok := infix.Right.(*ast.IntegerLiteral)
+				if lok && rok {
+					var value int64
+					switch infix.Operator {
+					case "+":
+						value = left.Value + right.Value
+					case "*":
+						value = left.Value * right.Value
+					}
+					c.Replace(&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(value)}, Value: value})
+				}
+				return true
+			},
+			"let x = 7;",
+		},
+		// pre returning false skips the children and post
+		{
+			"fn(x) { x }; x",
+			func(c *ast.Cursor) bool {
+				_, isFunction := c.Node().(*ast.FunctionLiteral)
+				return !isFunction
+			},
+			func(c *ast.Cursor) bool {
+				if _, ok := c.Node().(*ast.Identifier); ok {
+					c.Replace(identifier("y"))
+				}
+				if _, ok := c.Node().(*ast.FunctionLiteral); ok {
+					c.Replace(identifier("never"))
+				}
+				return true
+			},
+			"fn(x) {x;}y",
+		},
+		// post returning false ends the traversal
+		{
+			"a; b; c",
+			nil,
+			func(c *ast.Cursor) bool {
+				if id, ok := c.Node().(*ast.Identifier); ok {
+					c.Replace(identifier(strings.ToUpper(id.Value)))
+					return id.Value != "b"
+				}
+				return true
+			},
+			"ABc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := parse(t, tt.input)
+			result := ast.Apply(program, tt.pre, tt.post)
+			if result != ast.Node(program) {
+				t.Fatalf("Apply returned %v, expected the program", result)
+			}
+			if program.String() != tt.expected {
+				t.Fatalf("program wrong.\nexpected=%q\ngot=     %q", tt.expected, program.String())
+			}
+		})
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	program := parse(t, "1")
+	replacement := &ast.Program{}
+
+	result := ast.Apply(program, func(c *ast.Cursor) bool {
+		if c.Parent() == nil {
+			c.Replace(replacement)
+		}
+		return true
+	}, nil)
+	if result != ast.Node(replacement) {
+		t.Fatalf("Apply returned %v, expected the replacement", result)
+	}
+}
+
+func TestApplyPanics(t *testing.T) {
+	tests := []struct {
+		input    string
+		edit     func(c *ast.Cursor)
+		expected string
+	}{
+		{"a", func(c *ast.Cursor) {
+			if _, ok := c.Node().(*ast.ExpressionStatement); ok {
+				c.Replace(identifier("b"))
+			}
+		}, "ast: *ast.Identifier is not a statement"},
+		{"let a = 1", func(c *ast.Cursor) {
+			if c.Name() == "Name" {
+				c.Replace(&ast.IntegerLiteral{})
+			}
+		}, "ast: *ast.IntegerLiteral is not an identifier"},
+		{"while (a) {}", func(c *ast.Cursor) {
+			if c.Name() == "Body" {
+				c.Replace(&ast.HashLiteral{})
+			}
+		}, "ast: *ast.HashLiteral is not a block"},
+		{"-a", func(c *ast.Cursor) {
+			if c.Name() == "Right" {
+				c.Replace(&ast.BreakStatement{})
+			}
+		}, "ast: *ast.BreakStatement is not an expression"},
+		{"-a", func(c *ast.Cursor) {
+			if c.Name() == "Right" {
+				c.Delete()
+			}
+		}, "ast: Delete of a node not contained in a list"},
+		{"-a", func(c *ast.Cursor) {
+			if c.Name() == "Right" {
+				c.InsertAfter(identifier("b"))
+			}
+		}, "ast: InsertAfter of a node not contained in a list"},
+		{"-a", func(c *ast.Cursor) {
+			if c.Name() == "Right" {
+				c.InsertBefore(identifier("b"))
+			}
+		}, "ast: InsertBefore of a node not contained in a list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatalf("expected a panic")
+				}
+				if fmt.Sprint(r) != tt.expected {
+					t.Fatalf("panic wrong. expected=%q, got=%q", tt.expected, r)
+				}
+			}()
+			ast.Apply(parse(t, tt.input), func(c *ast.Cursor) bool {
+				tt.edit(c)
+				return true
+			}, nil)
+		})
+	}
+}
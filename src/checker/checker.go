@@ -0,0 +1,332 @@
+package checker
+
+import (
+	"ast"
+	"fmt"
+	"sort"
+	"token"
+)
+
+// binding is a name declared by let, const or a function parameter.
+type binding struct {
+	pos      token.Position
+	constant bool
+}
+
+// scope holds the bindings of a function, parameters included, or of the top
+// level of a program. As at runtime, blocks have no scope of their own: a let
+// in the body of an if or a loop binds its name in the enclosing function.
+// Only the branches of an if expression, which exclude each other, may both
+// declare a name.
+type scope struct {
+	bindings map[string]binding
+	outer    *scope
+
+	// bound holds, in the scope of a function, where each of its local names
+	// is first bound. The function reserves them all when it is called, so
+	// they cannot refer to an outer binding before that.
+	bound map[string]bound
+}
+
+// bound is the first binding of a local name: pos is the name in the
+// statement or parameter binding it, which takes effect at offset ready.
+type bound struct {
+	pos   token.Position
+	ready int
+}
+
+// bind records that name is bound at ready, unless it was bound earlier.
+func (s *scope) bind(name *ast.Identifier, ready int) {
+	if b, ok := s.bound[name.Value]; !ok || ready < b.ready {
+		s.bound[name.Value] = bound{pos: name.Pos(), ready: ready}
+	}
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{bindings: make(map[string]binding), outer: outer}
+}
+
+func (s *scope) resolve(name string) (binding, bool) {
+	for sc := s; sc != nil; sc = sc.outer {
+		if b, ok := sc.bindings[name]; ok {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+type checkError struct {
+	pos token.Position
+	msg string
+}
+
+type checker struct {
+	errors []checkError
+}
+
+// Check statically checks a parsed program before it runs. It reports a name
+// declared twice in the same function, or at the top level, and an assignment
+// to a constant, with the same "line:column: message" format as the parser
+// errors.
+//
+// Declarations are visible in their whole function, also before the statement
+// that declares them, so a function may refer to a name declared after it. For
+// loop variables and catch parameters are no declarations: they rebind their
+// name in the enclosing function, which must not be a constant there.
+//
+// Inside a function a local name cannot be used before the statement or the
+// parameter that binds it, except in nested functions, which may run later.
+func Check(program *ast.Program) []string {
+	return New().Check(program)
+}
+
+// Checker checks programs one after another, as the REPL runs them, so that a
+// program cannot redeclare what an earlier one declared at the top level.
+type Checker struct {
+	top *scope
+}
+
+func New() *Checker {
+	return &Checker{top: newScope(nil)}
+}
+
+// Check checks program like the Check function, taking into account the
+// declarations of the programs passed to Declare before.
+func (ch *Checker) Check(program *ast.Program) []string {
+	top := newScope(nil)
+	top.bindings = copyBindings(ch.top.bindings)
+
+	c := &checker{}
+	c.declareLets(program, top)
+	for _, statement := range program.Statements {
+		c.check(statement, top)
+	}
+
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		return c.errors[i].pos.Offset < c.errors[j].pos.Offset
+	})
+	var errors []string
+	for _, err := range c.errors {
+		errors = append(errors, fmt.Sprintf("%s: %s", err.pos, err.msg))
+	}
+	return errors
+}
+
+// Declare records the top-level declarations of program, which passed Check
+// and ran, for the programs checked after it. A program that failed at runtime
+// or skipped a let does not bind every name it declares, so only the names for
+// which bound reports true are recorded.
+func (ch *Checker) Declare(program *ast.Program, bound func(name string) bool) {
+	declared := newScope(nil)
+	(&checker{}).declareLets(program, declared)
+	for name, b := range declared.bindings {
+		if bound(name) {
+			ch.top.bindings[name] = b
+		}
+	}
+}
+
+func (c *checker) addError(pos token.Position, msg string, args ...interface{}) {
+	c.errors = append(c.errors, checkError{pos: pos, msg: fmt.Sprintf(msg, args...)})
+}
+
+func (c *checker) declare(s *scope, name *ast.Identifier, constant bool) {
+	if previous, ok := s.bindings[name.Value]; ok {
+		kind := "variable"
+		if previous.constant {
+			kind = "constant"
+		}
+		c.addError(name.Pos(), "cannot redeclare %s %s (declared at %s)", kind, name.Value, previous.pos)
+		return
+	}
+	s.bindings[name.Value] = binding{pos: name.Pos(), constant: constant}
+}
+
+// declareLets declares every let and const statement inside node in s,
+// without descending into function literals, which have scopes of their own.
+// Only one branch of an if expression runs, so the branches may declare the
+// same name.
+func (c *checker) declareLets(node ast.Node, s *scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.IfExpression:
+			c.declareLets(node.Condition, s)
+			before := s.bindings
+			s.bindings = copyBindings(before)
+			c.declareLets(node.Consequence, s)
+			consequence := s.bindings
+			s.bindings = copyBindings(before)
+			if node.Alternative != nil {
+				c.declareLets(node.Alternative, s)
+			}
+			for name, b := range consequence {
+				s.bindings[name] = b
+			}
+			return false
+		case *ast.LetStatement:
+			c.declare(s, node.Name, node.IsConst())
+		}
+		return true
+	})
+}
+
+func copyBindings(bindings map[string]binding) map[string]binding {
+	copied := make(map[string]binding, len(bindings))
+	for name, b := range bindings {
+		copied[name] = b
+	}
+	return copied
+}
+
+// bindLocals records in s where the names bound by let statements, for loops
+// and catch clauses inside node are bound, without descending into function
+// literals.
+func bindLocals(node ast.Node, s *scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			s.bind(node.Name, node.End().Offset)
+		case *ast.ForStatement:
+			s.bind(node.Variable, node.Body.Pos().Offset)
+		case *ast.TryExpression:
+			if node.Catch != nil {
+				s.bind(node.Parameter, node.Catch.Pos().Offset)
+			}
+		}
+		return true
+	})
+}
+
+// use checks that name, used in the function of scope s, is not one of its
+// local names before that is bound. It reports whether the use is valid.
+func (c *checker) use(s *scope, name *ast.Identifier) bool {
+	if b, ok := s.bound[name.Value]; ok && name.Pos().Offset < b.ready {
+		c.addError(name.Pos(), "%s used before it is bound (at %s)", name.Value, b.pos)
+		return false
+	}
+	return true
+}
+
+// rebind checks the variable of a for loop or the parameter of a catch, which
+// is bound in s without being declared.
+func (c *checker) rebind(s *scope, name *ast.Identifier) {
+	if b, ok := s.bindings[name.Value]; ok && b.constant {
+		c.addError(name.Pos(), "cannot redeclare constant %s (declared at %s)", name.Value, b.pos)
+	}
+}
+
+func (c *checker) checkBlock(block *ast.BlockStatement, s *scope) {
+	if block != nil {
+		for _, statement := range block.Statements {
+			c.check(statement, s)
+		}
+	}
+}
+
+func (c *checker) check(node ast.Node, s *scope) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		c.check(node.Value, s)
+	case *ast.ReturnStatement:
+		c.check(node.ReturnValue, s)
+	case *ast.ThrowStatement:
+		c.check(node.Value, s)
+	case *ast.ExpressionStatement:
+		c.check(node.Expression, s)
+	case *ast.BlockStatement:
+		c.checkBlock(node, s)
+	case *ast.WhileStatement:
+		c.check(node.Condition, s)
+		c.checkBlock(node.Body, s)
+	case *ast.ForStatement:
+		c.check(node.Iterable, s)
+		c.rebind(s, node.Variable)
+		c.checkBlock(node.Body, s)
+	case *ast.TryExpression:
+		c.checkBlock(node.Block, s)
+		if node.Catch != nil {
+			c.rebind(s, node.Parameter)
+			c.checkBlock(node.Catch, s)
+		}
+		c.checkBlock(node.Finally, s)
+	case *ast.IfExpression:
+		c.check(node.Condition, s)
+		c.checkBlock(node.Consequence, s)
+		c.checkBlock(node.Alternative, s)
+	case *ast.FunctionLiteral:
+		body := newScope(s)
+		body.bound = make(map[string]bound)
+		for i, parameter := range node.Parameters {
+			c.declare(body, parameter, false)
+			// a parameter is bound once its default value is evaluated
+			if value := node.Default(i); value != nil {
+				body.bind(parameter, value.End().Offset)
+			} else {
+				body.bind(parameter, parameter.End().Offset)
+			}
+		}
+		for _, value := range node.Defaults {
+			if value != nil {
+				bindLocals(value, body)
+			}
+		}
+		bindLocals(node.Body, body)
+		for _, value := range node.Defaults {
+			if value != nil {
+				c.declareLets(value, body)
+			}
+		}
+		c.declareLets(node.Body, body)
+		for _, value := range node.Defaults {
+			c.check(value, body)
+		}
+		c.checkBlock(node.Body, body)
+	case *ast.Identifier:
+		c.use(s, node)
+	case *ast.AssignExpression:
+		if target, ok := node.Target.(*ast.Identifier); ok {
+			if b, ok := s.resolve(target.Value); c.use(s, target) && ok && b.constant {
+				c.addError(target.Pos(), "cannot assign to constant %s (declared at %s)", target.Value, b.pos)
+			}
+		} else {
+			c.check(node.Target, s)
+		}
+		c.check(node.Value, s)
+	case *ast.PrefixExpression:
+		c.check(node.Right, s)
+	case *ast.InfixExpression:
+		c.check(node.Left, s)
+		c.check(node.Right, s)
+	case *ast.CallExpression:
+		c.check(node.Function, s)
+		for _, argument := range node.Arguments {
+			c.check(argument, s)
+		}
+	case *ast.SpreadExpression:
+		c.check(node.Value, s)
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			c.check(element, s)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			c.check(part, s)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.check(pair.Key, s)
+			c.check(pair.Value, s)
+		}
+	case *ast.IndexExpression:
+		c.check(node.Left, s)
+		c.check(node.Index, s)
+	case *ast.SliceExpression:
+		c.check(node.Left, s)
+		c.check(node.Low, s)
+		c.check(node.High, s)
+	}
+}
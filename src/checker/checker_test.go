@@ -0,0 +1,114 @@
+package checker
+
+import (
+	"lexer"
+	"parser"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; a = 2; let b = a;", nil},
+		{"if (true) { let a = 2 } else { let b = 3 }", nil},
+		{"let f = fn(a) { let b = a; fn(b) { let a = b } }", nil},
+		{"const a = 1; let f = fn() { let a = 2; a = 3 }", nil},
+		{"for (x in [1]) { let y = x }; for (x in [2]) { y = x }", nil},
+		{"let x = 0; for (x in [1]) { }; try { 1 } catch (x) { x }", nil},
+		{"let f = fn() { while (true) { let a = 1 } }; let a = 2", nil},
+		{"try { 1 } catch (e) { let err = e }", nil},
+		{"const a = [1]; a[0] = 2", nil},
+		{"let a = 1; let a = 2", []string{"1:16: cannot redeclare variable a (declared at 1:5)"}},
+		{"const a = 1; let a = 2", []string{"1:18: cannot redeclare constant a (declared at 1:7)"}},
+		{"let f = fn(x) { let x = 1 }", []string{"1:21: cannot redeclare variable x (declared at 1:12)"}},
+		// blocks share the scope of the enclosing function, as at runtime
+		{"let a = 1; if (true) { let a = 2 }", []string{"1:28: cannot redeclare variable a (declared at 1:5)"}},
+		{"const a = 1; if (true) { let a = 2 }", []string{"1:30: cannot redeclare constant a (declared at 1:7)"}},
+		{"for (x in [1]) { let y = x }; for (x in [2]) { let y = x }", []string{"1:52: cannot redeclare variable y (declared at 1:22)"}},
+		{"let f = fn(x) { if (x) { let x = 1 } }", []string{"1:30: cannot redeclare variable x (declared at 1:12)"}},
+		// but only one branch of an if runs
+		{"let c = true; if (c) { let a = 1 } else { let a = 2 }; a", nil},
+		{"if (true) { let a = 1 } else { if (false) { let a = 2 } else { const a = 3 } }", nil},
+		{"while (true) { if (true) { let a = 1 } else { let a = 2 } }", nil},
+		{"if (true) { let a = 1 } else { let a = 2 }; let a = 3", []string{"1:49: cannot redeclare variable a (declared at 1:17)"}},
+		{"let a = 0; if (true) { 1 } else { let a = 2 }", []string{"1:39: cannot redeclare variable a (declared at 1:5)"}},
+		{"if (true) { let a = 1; let a = 2 }", []string{"1:28: cannot redeclare variable a (declared at 1:17)"}},
+		{"const x = 1; for (x in [5]) { }", []string{"1:19: cannot redeclare constant x (declared at 1:7)"}},
+		{"const e = 1; try { throw 2 } catch (e) { }", []string{"1:37: cannot redeclare constant e (declared at 1:7)"}},
+		{"const a = 1; a = 2", []string{"1:14: cannot assign to constant a (declared at 1:7)"}},
+		{"let f = fn(a, ...a) { a }", []string{"1:18: cannot redeclare variable a (declared at 1:12)"}},
+		{"const c = 1; let f = fn(a = c = 2) { a }; f(...[c = 3])", []string{
+			"1:29: cannot assign to constant c (declared at 1:7)",
+			"1:49: cannot assign to constant c (declared at 1:7)",
+		}},
+		{"const a = 1; [1, fn() { if (true) { a += 1 } }]", []string{"1:37: cannot assign to constant a (declared at 1:7)"}},
+		// declarations are visible in their whole function, but local names
+		// can only be used by nested functions before they are bound
+		{"let f = fn() { let g = fn() { a = 2 }; if (true) { const a = 1 } }", []string{"1:31: cannot assign to constant a (declared at 1:58)"}},
+		{"let x = 5; let f = fn() { let y = x; let x = 3; y }", []string{"1:35: x used before it is bound (at 1:42)"}},
+		{"let x = 5; let f = fn() { let x = x + 1 }", []string{"1:35: x used before it is bound (at 1:31)"}},
+		{"let x = 5; let f = fn() { x = 1; let x = 2 }", []string{"1:27: x used before it is bound (at 1:38)"}},
+		{"let f = fn() { a = 2; if (true) { const a = 1 } }", []string{"1:16: a used before it is bound (at 1:41)"}},
+		{"let i = 5; let f = fn() { let a = i; for (i in [1]) { i } }", []string{"1:35: i used before it is bound (at 1:43)"}},
+		{"let e = 5; let f = fn() { e; try { 1 } catch (e) { e } }", []string{"1:27: e used before it is bound (at 1:47)"}},
+		{"let b = 9; let f = fn(a = b, b = a) { a }", []string{"1:27: b used before it is bound (at 1:30)"}},
+		{"let f = fn() { let g = fn() { x }; let x = 3; g() }", nil},
+		{"let f = fn(a, b = a) { for (i in [a]) { i }; try { b } catch (e) { e } }", nil},
+		// errors are reported in source order
+		{"let f = fn() { let b = 1; let b = 2 }; let a = 1; let a = 2", []string{
+			"1:31: cannot redeclare variable b (declared at 1:20)",
+			"1:55: cannot redeclare variable a (declared at 1:44)",
+		}},
+		{"let f = fn() { a = 2 }; const a = 1", []string{"1:16: cannot assign to constant a (declared at 1:31)"}},
+		{"let a = 1;\nlet a = 2;\nconst c = 1;\nc = 2", []string{
+			"2:5: cannot redeclare variable a (declared at 1:5)",
+			"4:1: cannot assign to constant c (declared at 3:7)",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		errors := Check(program)
+		if !reflect.DeepEqual(errors, tt.expected) {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestCheckerDeclare(t *testing.T) {
+	checker := New()
+	inputs := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; const c = 2", nil},
+		{"let a = 2", []string{"1:5: cannot redeclare variable a (declared at 1:5)"}},
+		{"c = 3", []string{"1:1: cannot assign to constant c (declared at 1:18)"}},
+		{"let f = fn() { let a = 1 }; a = 3", nil},
+		// not declared since it did not pass the check
+		{"let b = 1; let b = 2", []string{"1:16: cannot redeclare variable b (declared at 1:5)"}},
+		{"let b = 3", nil},
+		// not declared since it was not bound when the program ran
+		{"let skipped = 1", nil},
+		{"let skipped = 2", nil},
+	}
+
+	for _, tt := range inputs {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		errors := checker.Check(program)
+		if !reflect.DeepEqual(errors, tt.expected) {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, errors)
+		}
+		if len(errors) == 0 {
+			checker.Declare(program, func(name string) bool { return name != "skipped" })
+		}
+	}
+}
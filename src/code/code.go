@@ -0,0 +1,260 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		definition, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i += 1
+			continue
+		}
+
+		operands, read := ReadOperands(definition, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(definition, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(definition *Definition, operands []int) string {
+	operandCount := len(definition.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return definition.Name
+	case 1:
+		return fmt.Sprintf("%s %d", definition.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", definition.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", definition.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJump
+	OpJumpNotTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetOuter
+	OpSetOuter
+	OpAssignGlobal
+	OpAssignLocal
+	OpAssignOuter
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSlice
+	OpSetIndex
+	OpDup
+	OpInterpolate
+
+	OpClosure
+	OpCall
+	OpCallSpread
+	OpReturnValue
+	OpJumpIfBound
+
+	OpThrow
+	OpSetupTry
+	OpEndTry
+	OpEndFinally
+
+	OpSetupLoop
+	OpPopLoop
+	OpBreak
+	OpContinue
+	OpIterator
+	OpNext
+)
+
+// Slice flags tell OpSlice which of the optional bounds were pushed.
+const (
+	SliceLow  = 1 << 0
+	SliceHigh = 1 << 1
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{2}},
+	OpSetLocal:  {"OpSetLocal", []int{2}},
+	// outer operands are the number of enclosing functions to walk up and the slot there
+	OpGetOuter: {"OpGetOuter", []int{2, 2}},
+	OpSetOuter: {"OpSetOuter", []int{2, 2}},
+	// assignments leave the value on the stack and fail if the slot was never set
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpAssignLocal:  {"OpAssignLocal", []int{2}},
+	OpAssignOuter:  {"OpAssignOuter", []int{2, 2}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSlice:    {"OpSlice", []int{1}},
+	OpSetIndex: {"OpSetIndex", []int{}},
+	// operand is the number of values on top of the stack to duplicate
+	OpDup: {"OpDup", []int{1}},
+	// operand is the number of parts on the stack to join into a string
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpClosure: {"OpClosure", []int{2}},
+	OpCall:    {"OpCall", []int{2}},
+	// operand is the number of arrays on the stack whose elements are the arguments
+	OpCallSpread:  {"OpCallSpread", []int{2}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	// operands are a local slot and the address to jump to if an argument was passed for it
+	OpJumpIfBound: {"OpJumpIfBound", []int{2, 2}},
+
+	OpThrow: {"OpThrow", []int{}},
+	// operands are the addresses of the catch and finally blocks, 0 if absent
+	OpSetupTry:   {"OpSetupTry", []int{2, 2}},
+	OpEndTry:     {"OpEndTry", []int{}},
+	OpEndFinally: {"OpEndFinally", []int{}},
+
+	// operands are the addresses break and continue jump to
+	OpSetupLoop: {"OpSetupLoop", []int{2, 2}},
+	OpPopLoop:   {"OpPopLoop", []int{}},
+	OpBreak:     {"OpBreak", []int{}},
+	OpContinue:  {"OpContinue", []int{}},
+	OpIterator:  {"OpIterator", []int{}},
+	// operand is the address to jump to once the iterator is exhausted
+	OpNext: {"OpNext", []int{2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	definition, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return definition, nil
+}
+
+// Make encodes op and its operands into a single instruction.
+func Make(op Opcode, operands ...int) []byte {
+	definition, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range definition.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := definition.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(definition *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(definition.OperandWidths))
+	offset := 0
+
+	for i, width := range definition.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,834 @@
+package compiler
+
+import (
+	"ast"
+	"code"
+	"evaluator"
+	"fmt"
+	"object"
+	"strings"
+	"token"
+)
+
+// Bytecode is the output of the compiler: the instructions of the top level
+// program plus the constant pool they refer to.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    map[int]token.Span // instruction offset -> source span
+	GlobalNames  []string           // names of the global slots, for error messages
+}
+
+type CompilationScope struct {
+	instructions code.Instructions
+	positions    map[int]token.Span
+	declared     map[string]bool // names declared by let statements and parameters
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// nodes is the chain of nodes being compiled, innermost last. Each emitted
+	// instruction is attributed to the innermost one, which is the node
+	// evaluator.Eval would blame for a runtime error.
+	nodes []ast.Node
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+}
+
+var prefixOperators = map[string]code.Opcode{
+	"!": code.OpBang,
+	"-": code.OpMinus,
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState creates a compiler that continues from the globals and
+// constants of a previous compilation, as the REPL does line by line.
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: symbolTable,
+		scopes: []CompilationScope{
+			{instructions: code.Instructions{}, positions: make(map[int]token.Span), declared: make(map[string]bool)},
+		},
+	}
+}
+
+// maxOperand is the largest value of a two-byte operand: a constant index, a
+// jump target, a local index or an argument count.
+const maxOperand = 65535
+
+func (c *Compiler) Compile(node ast.Node) error {
+	c.nodes = append(c.nodes, node)
+	defer func() {
+		c.nodes = c.nodes[:len(c.nodes)-1]
+	}()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		if err := c.compileStatements(node.Statements, false); err != nil {
+			return err
+		}
+		return c.checkSize(node, "program", c.currentInstructions())
+
+	case *ast.ExpressionStatement:
+		return c.Compile(node.Expression)
+
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements, true)
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BreakStatement:
+		c.emit(code.OpBreak)
+
+	case *ast.ContinueStatement:
+		c.emit(code.OpContinue)
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(evaluator.IntegerLiteral(node)))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		// only one branch runs, so both may declare the same name, and a
+		// constant of the consequence is none in the alternative
+		before := copyDeclared(c.scopes[c.scopeIndex].declared)
+		if err := c.Compile(node.Consequence); err != nil {
+			return err
+		}
+		consequence := c.scopes[c.scopeIndex].declared
+		c.scopes[c.scopeIndex].declared = before
+		var constants []string
+		for name := range consequence {
+			if symbol := c.symbolTable.store[name]; symbol.Const && !before[name] {
+				symbol.Const = false
+				c.symbolTable.store[name] = symbol
+				constants = append(constants, name)
+			}
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else if err := c.Compile(node.Alternative); err != nil {
+			return err
+		}
+		for name := range consequence {
+			c.scopes[c.scopeIndex].declared[name] = true
+		}
+		for _, name := range constants {
+			c.symbolTable.DefineConst(name)
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.Identifier:
+		return c.compileIdentifier(node)
+
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			if err := c.Compile(element); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		flags := 0
+		if node.Low != nil {
+			if err := c.Compile(node.Low); err != nil {
+				return err
+			}
+			flags |= code.SliceLow
+		}
+		if node.High != nil {
+			if err := c.Compile(node.High); err != nil {
+				return err
+			}
+			flags |= code.SliceHigh
+		}
+		c.emit(code.OpSlice, flags)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		return c.compileCallExpression(node)
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+// compileCallExpression pushes the function and its arguments for OpCall. A
+// call with spread arguments pushes arrays instead, one per spread argument
+// and one per run of other arguments, for OpCallSpread to concatenate.
+func (c *Compiler) compileCallExpression(node *ast.CallExpression) error {
+	if err := c.Compile(node.Function); err != nil {
+		return err
+	}
+
+	hasSpread := false
+	for _, argument := range node.Arguments {
+		if _, ok := argument.(*ast.SpreadExpression); ok {
+			hasSpread = true
+		}
+	}
+	if !hasSpread {
+		if len(node.Arguments) > maxOperand {
+			return fmt.Errorf("%s: too many arguments in call: %d", node.Pos(), len(node.Arguments))
+		}
+		for _, argument := range node.Arguments {
+			if err := c.Compile(argument); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+		return nil
+	}
+
+	parts, run := 0, 0
+	for _, argument := range node.Arguments {
+		spread, ok := argument.(*ast.SpreadExpression)
+		if !ok {
+			if err := c.Compile(argument); err != nil {
+				return err
+			}
+			run++
+			continue
+		}
+		if run > 0 {
+			c.emit(code.OpArray, run)
+			parts, run = parts+1, 0
+		}
+		if err := c.Compile(spread.Value); err != nil {
+			return err
+		}
+		parts++
+	}
+	if run > 0 {
+		c.emit(code.OpArray, run)
+		parts++
+	}
+	if parts > maxOperand {
+		return fmt.Errorf("%s: too many spread and argument groups in call: %d", node.Pos(), parts)
+	}
+	c.emit(code.OpCallSpread, parts)
+	return nil
+}
+
+// compileLogicalExpression lays out a && b as
+//
+//	<a> OpBang OpBang OpJumpNotTruthy false
+//	<b> OpBang OpBang OpJump end
+//	false: OpFalse
+//	end:
+//
+// and a || b the other way round. The double OpBang turns an operand into the
+// boolean the evaluator judges it by.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if node.Operator == "&&" {
+		if err := c.compileBoolean(node.Right); err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+
+	c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if err := c.compileBoolean(node.Right); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileBoolean(node ast.Expression) error {
+	if err := c.Compile(node); err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	return nil
+}
+
+// compileStatements compiles a statement list. Values of expression statements
+// are discarded except for the last one. A block always leaves exactly one
+// value on the stack, NULL if its last statement produces none.
+func (c *Compiler) compileStatements(statements []ast.Statement, isBlock bool) error {
+	for i, statement := range statements {
+		if err := c.Compile(statement); err != nil {
+			return err
+		}
+		if _, ok := statement.(*ast.ExpressionStatement); ok && i < len(statements)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+
+	if isBlock {
+		if len(statements) == 0 {
+			c.emit(code.OpNull)
+		} else if _, ok := statements[len(statements)-1].(*ast.ExpressionStatement); !ok {
+			c.emit(code.OpNull)
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileIdentifier(identifier *ast.Identifier) error {
+	if identifier.Value == "null" {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	symbol, depth, ok := c.symbolTable.Resolve(identifier.Value)
+	if !ok {
+		if fn, ok := evaluator.LookupBuildin(identifier.Value); ok {
+			c.emit(code.OpConstant, c.addConstant(fn))
+			return nil
+		}
+		// Not defined yet: reserve a global slot. If a later `let` defines the
+		// name it fills this slot, otherwise reading it is a runtime error, as
+		// with the environment lookup of the evaluator.
+		symbol = c.symbolTable.Global().Define(identifier.Value)
+	}
+
+	switch {
+	case symbol.Scope == GlobalScope:
+		c.emit(code.OpGetGlobal, symbol.Index)
+	case depth == 0:
+		c.emit(code.OpGetLocal, symbol.Index)
+	default:
+		c.emit(code.OpGetOuter, depth, symbol.Index)
+	}
+	return nil
+}
+
+// compileTryExpression lays out a try expression as
+//
+//	OpSetupTry catch finally
+//	<try block>
+//	OpEndTry
+//	OpJump finally (or end)
+//	catch:   <bind the caught value> <catch block> OpEndTry
+//	finally: <finally block> OpPop OpEndFinally
+//	end:
+//
+// The VM jumps to catch with the caught value pushed, and to finally with NULL
+// pushed in place of the value when an error or return passes through.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	setupPos := c.emit(code.OpSetupTry, 9999, 9999)
+
+	if err := c.Compile(node.Block); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	catchPos := 0
+	if node.Catch != nil {
+		catchPos = len(c.currentInstructions())
+
+		// the caught value is bound in the enclosing scope, which must not
+		// have declared the name as a constant
+		if err := c.checkRedeclaration(node.Parameter.Value, node.Parameter.Pos(), false); err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Parameter.Value)
+		c.setSymbol(symbol, 0)
+
+		if err := c.Compile(node.Catch); err != nil {
+			return err
+		}
+		c.emit(code.OpEndTry)
+	}
+
+	finallyPos := 0
+	if node.Finally != nil {
+		finallyPos = len(c.currentInstructions())
+
+		if err := c.Compile(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		c.emit(code.OpEndFinally)
+	}
+
+	c.changeOperand(setupPos, catchPos, finallyPos)
+	if finallyPos != 0 {
+		c.changeOperand(jumpPos, finallyPos)
+	} else {
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileLetStatement binds a let or const. Like the evaluator it refuses to
+// redeclare a name of the same scope.
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	if err := c.checkRedeclaration(node.Name.Value, node.Pos(), true); err != nil {
+		return err
+	}
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	c.scopes[c.scopeIndex].declared[node.Name.Value] = true
+
+	var symbol Symbol
+	if node.IsConst() {
+		symbol, _ = c.symbolTable.DefineConst(node.Name.Value)
+	} else {
+		symbol = c.symbolTable.Define(node.Name.Value)
+	}
+	c.setSymbol(symbol, 0)
+	return nil
+}
+
+// compileAssignExpression leaves the assigned value on the stack. Compound
+// assignments read the target before the value is evaluated.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	op, compound := infixOperators[strings.TrimSuffix(node.Operator, "=")]
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, depth, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			if _, isBuildin := evaluator.LookupBuildin(target.Value); isBuildin || target.Value == "null" {
+				return fmt.Errorf("%s: cannot assign to %s", node.Pos(), target.Value)
+			}
+			// like reading an undefined name, see compileIdentifier
+			symbol = c.symbolTable.Global().Define(target.Value)
+		}
+		if symbol.Const {
+			return fmt.Errorf("%s: cannot assign to constant %s", node.Pos(), target.Value)
+		}
+
+		if compound {
+			if err := c.compileIdentifier(target); err != nil {
+				return err
+			}
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+
+		switch {
+		case symbol.Scope == GlobalScope:
+			c.emit(code.OpAssignGlobal, symbol.Index)
+		case depth == 0:
+			c.emit(code.OpAssignLocal, symbol.Index)
+		default:
+			c.emit(code.OpAssignOuter, depth, symbol.Index)
+		}
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(code.OpDup, 2)
+			c.emit(code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", node.Pos(), node.Target)
+	}
+	return nil
+}
+
+// compileWhileStatement lays out a while loop as
+//
+//	OpSetupLoop end condition
+//	condition: <condition> OpJumpNotTruthy exit
+//	<body> OpPop OpJump condition
+//	exit: OpPopLoop
+//	end:
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	setupPos := c.emit(code.OpSetupLoop, 9999, 9999)
+
+	conditionPos := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpJump, conditionPos)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	c.emit(code.OpPopLoop)
+	c.changeOperand(setupPos, len(c.currentInstructions()), conditionPos)
+	return nil
+}
+
+// compileForStatement lays out a for-in loop as
+//
+//	<iterable> OpIterator
+//	OpSetupLoop end next
+//	next: OpNext exit
+//	<bind the element> <body> OpPop OpJump next
+//	exit: OpPopLoop
+//	end:  OpPop
+//
+// The iterator stays on the stack below the loop until the final OpPop.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIterator)
+	setupPos := c.emit(code.OpSetupLoop, 9999, 9999)
+
+	nextPos := c.emit(code.OpNext, 9999)
+	// the element is bound in the enclosing scope, which must not have
+	// declared the name as a constant
+	if err := c.checkRedeclaration(node.Variable.Value, node.Variable.Pos(), false); err != nil {
+		return err
+	}
+	symbol := c.symbolTable.Define(node.Variable.Value)
+	c.setSymbol(symbol, 0)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpJump, nextPos)
+
+	c.changeOperand(nextPos, len(c.currentInstructions()))
+	c.emit(code.OpPopLoop)
+	c.changeOperand(setupPos, len(c.currentInstructions()), nextPos)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// checkRedeclaration returns an error if name cannot be bound in the current
+// scope because it is a constant there or, for a declaration, because a let
+// statement or a parameter already declared it.
+func (c *Compiler) checkRedeclaration(name string, pos token.Position, declaration bool) error {
+	if symbol, ok := c.symbolTable.store[name]; ok && symbol.Const {
+		return fmt.Errorf("%s: cannot redeclare constant %s", pos, name)
+	}
+	if declaration && c.scopes[c.scopeIndex].declared[name] {
+		return fmt.Errorf("%s: cannot redeclare variable %s", pos, name)
+	}
+	return nil
+}
+
+func copyDeclared(declared map[string]bool) map[string]bool {
+	copied := make(map[string]bool, len(declared))
+	for name := range declared {
+		copied[name] = true
+	}
+	return copied
+}
+
+func (c *Compiler) setSymbol(symbol Symbol, depth int) {
+	switch {
+	case symbol.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case depth == 0:
+		c.emit(code.OpSetLocal, symbol.Index)
+	default:
+		c.emit(code.OpSetOuter, depth, symbol.Index)
+	}
+}
+
+func (c *Compiler) compileFunctionLiteral(literal *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	for _, parameter := range literal.Parameters {
+		c.symbolTable.Define(parameter.Value)
+		c.scopes[c.scopeIndex].declared[parameter.Value] = true
+	}
+	// Every `let` in the body gets its slot up front so that closures created
+	// before the binding is executed still see it once it is.
+	for _, value := range literal.Defaults {
+		if value != nil {
+			declareLocals(c.symbolTable, value)
+		}
+	}
+	declareLocals(c.symbolTable, literal.Body)
+
+	// the default values of the parameters no argument was passed for
+	for i, value := range literal.Defaults {
+		if value == nil {
+			continue
+		}
+		jumpPos := c.emit(code.OpJumpIfBound, i, 9999)
+		if err := c.Compile(value); err != nil {
+			c.leaveScope()
+			return err
+		}
+		c.emit(code.OpSetLocal, i)
+		c.changeOperand(jumpPos, i, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(literal.Body); err != nil {
+		c.leaveScope()
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.Names()
+	instructions, positions := c.leaveScope()
+
+	if numLocals > maxOperand+1 {
+		return fmt.Errorf("%s: too many local bindings in function: %d", literal.Pos(), numLocals)
+	}
+
+	minArguments, _ := literal.Arity()
+	compiledFunction := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(literal.Parameters),
+		MinArguments:  minArguments,
+		Variadic:      literal.Variadic,
+		LocalNames:    localNames,
+		Positions:     positions,
+		Literal:       literal,
+	}
+	c.emit(code.OpClosure, c.addConstant(compiledFunction))
+	return c.checkSize(literal, "function", instructions)
+}
+
+// checkSize returns an error at node if the constants or the instructions of
+// a function or program grew past what two-byte operands can address. Both
+// only grow, so if they fit at the end every operand emitted before fits too.
+func (c *Compiler) checkSize(node ast.Node, kind string, instructions code.Instructions) error {
+	if len(c.constants) > maxOperand+1 {
+		return fmt.Errorf("%s: too many constants: %d", node.Pos(), len(c.constants))
+	}
+	if len(instructions) > maxOperand {
+		return fmt.Errorf("%s: %s too long: %d bytes of bytecode", node.Pos(), kind, len(instructions))
+	}
+	return nil
+}
+
+// declareLocals defines every name bound by a let statement inside node,
+// without descending into nested function literals.
+func declareLocals(symbolTable *SymbolTable, node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			symbolTable.Define(node.Name.Value)
+		case *ast.ForStatement:
+			symbolTable.Define(node.Variable.Value)
+		case *ast.TryExpression:
+			if node.Catch != nil {
+				symbolTable.Define(node.Parameter.Value)
+			}
+		}
+		return true
+	})
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Positions:    c.scopes[c.scopeIndex].positions,
+		GlobalNames:  c.symbolTable.Global().Names(),
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	instruction := code.Make(op, operands...)
+	position := len(c.currentInstructions())
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.instructions = append(scope.instructions, instruction...)
+	if len(c.nodes) > 0 {
+		node := c.nodes[len(c.nodes)-1]
+		scope.positions[position] = token.Span{Pos: node.Pos(), End: node.End()}
+	}
+
+	return position
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) changeOperand(position int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[position])
+	instruction := code.Make(op, operands...)
+
+	copy(c.scopes[c.scopeIndex].instructions[position:], instruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{
+		instructions: code.Instructions{},
+		positions:    make(map[int]token.Span),
+		declared:     make(map[string]bool),
+	})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, map[int]token.Span) {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope.instructions, scope.positions
+}
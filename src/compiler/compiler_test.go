@@ -0,0 +1,579 @@
+package compiler
+
+import (
+	"ast"
+	"code"
+	"lexer"
+	"object"
+	"parser"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input:             "1.5 * 2",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+			},
+		},
+		{
+			input:             "-1 < 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "try { 1 } catch (e) { e } finally { 2 }",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpSetupTry, 12, 19),
+				// 0005
+				code.Make(code.OpConstant, 0),
+				// 0008
+				code.Make(code.OpEndTry),
+				// 0009
+				code.Make(code.OpJump, 19),
+				// 0012
+				code.Make(code.OpSetGlobal, 0),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpEndTry),
+				// 0019
+				code.Make(code.OpConstant, 1),
+				// 0022
+				code.Make(code.OpPop),
+				// 0023
+				code.Make(code.OpEndFinally),
+			},
+		},
+		{
+			input:             "try { 1 } catch (e) { 2 }",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpSetupTry, 12, 0),
+				// 0005
+				code.Make(code.OpConstant, 0),
+				// 0008
+				code.Make(code.OpEndTry),
+				// 0009
+				code.Make(code.OpJump, 19),
+				// 0012
+				code.Make(code.OpSetGlobal, 0),
+				// 0015
+				code.Make(code.OpConstant, 1),
+				// 0018
+				code.Make(code.OpEndTry),
+			},
+		},
+		{
+			input:             "throw 1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpThrow),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { break }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpSetupLoop, 16, 5),
+				// 0005
+				code.Make(code.OpTrue),
+				// 0006
+				code.Make(code.OpJumpNotTruthy, 15),
+				// 0009
+				code.Make(code.OpBreak),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpJump, 5),
+				// 0015
+				code.Make(code.OpPopLoop),
+			},
+		},
+		{
+			input:             "for (x in [1]) { continue }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIterator),
+				// 0007
+				code.Make(code.OpSetupLoop, 25, 12),
+				// 0012
+				code.Make(code.OpNext, 24),
+				// 0015
+				code.Make(code.OpSetGlobal, 0),
+				// 0018
+				code.Make(code.OpContinue),
+				// 0019
+				code.Make(code.OpNull),
+				// 0020
+				code.Make(code.OpPop),
+				// 0021
+				code.Make(code.OpJump, 12),
+				// 0024
+				code.Make(code.OpPopLoop),
+				// 0025
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let a = 1; a += 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpAssignGlobal, 0),
+			},
+		},
+		{
+			input:             "let a = []; a[0] *= 2",
+			expectedConstants: []interface{}{0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDup, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpSetIndex),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	for _, input := range []string{"len = 1", "null = 1"} {
+		compiler := New()
+		if err := compiler.Compile(parse(input)); err == nil {
+			t.Errorf("expected compile error for %q", input)
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "const a = 1; a",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"const a = 1; a = 2", "1:14: cannot assign to constant a"},
+		{"const a = 1; fn() { a += 2 }", "1:21: cannot assign to constant a"},
+		{"const a = 1; let a = 2", "1:14: cannot redeclare constant a"},
+		{"fn() { const a = 1; const a = 2 }", "1:21: cannot redeclare constant a"},
+		{"let a = 1; if (true) { let a = 2 }", "1:24: cannot redeclare variable a"},
+		{"fn(a) { let a = 2 }", "1:9: cannot redeclare variable a"},
+		{"if (true) { let a = 1 } else { let a = 2 }; let a = 3", "1:45: cannot redeclare variable a"},
+		{"let a = 1; if (true) { 1 } else { let a = 2 }", "1:35: cannot redeclare variable a"},
+		{"const x = 1; for (x in [5]) { }", "1:19: cannot redeclare constant x"},
+		{"const e = 1; try { throw 2 } catch (e) { }", "1:37: cannot redeclare constant e"},
+	}
+
+	for _, tt := range errors {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong compile error for %q. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCompilerLimits(t *testing.T) {
+	// the constants of ten functions of 7000 integers each
+	var functions []string
+	for i := 0; i < 10; i++ {
+		functions = append(functions, "fn() { ["+strings.Repeat("1, ", 6999)+"1] };")
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{strings.Join(functions, "\n"), "10:1: too many constants: 70010"},
+		{"[" + strings.Repeat("1, ", 21999) + "1]", "1:1: program too long: 66003 bytes of bytecode"},
+		{"let f = fn() {\n[" + strings.Repeat("x, ", 21999) + "x] }", "1:9: function too long: 66004 bytes of bytecode"},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong compile error for %.40q. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestLetStatementScopes(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = one; two;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+			},
+		},
+		{
+			input: "fn(a) { let b = a; fn() { b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetOuter, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+			},
+		},
+		{
+			// a name used before its let reserves the slot the let fills later
+			input: "let f = fn() { g }; let g = 1;",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpBang),
+				code.Make(code.OpJumpNotTruthy, 12),
+				code.Make(code.OpFalse),
+				code.Make(code.OpBang),
+				code.Make(code.OpBang),
+				code.Make(code.OpJump, 13),
+				code.Make(code.OpFalse),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpBang),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpTrue),
+				code.Make(code.OpJump, 13),
+				code.Make(code.OpFalse),
+				code.Make(code.OpBang),
+				code.Make(code.OpBang),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionParameters(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a, b = 2) { b }",
+			expectedConstants: []interface{}{
+				2,
+				[]code.Instructions{
+					code.Make(code.OpJumpIfBound, 1, 11),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+			},
+		},
+		{
+			input:             "f(1, ...xs, 2, 3)",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpCallSpread, 3),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `[1, 2][0:]`,
+			expectedConstants: []interface{}{1, 2, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSlice, code.SliceLow),
+			},
+		},
+		{
+			input:             `{"a": 1}["a"]`,
+			expectedConstants: []interface{}{"a", 1, "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+			},
+		},
+		{
+			input:             `"a${1}b"`,
+			expectedConstants: []interface{}{"a", 1, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpInterpolate, 3),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompilerPositions(t *testing.T) {
+	program := parse("let a = 1;\n-a")
+
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// OpGetGlobal for `a` is at offset 6 and OpMinus at offset 9
+	bytecode := compiler.Bytecode()
+	if span := bytecode.Positions[6]; span.Pos.String() != "2:2" || span.End.String() != "2:3" {
+		t.Errorf("span of identifier wrong. got=%s-%s", span.Pos, span.End)
+	}
+	if span := bytecode.Positions[9]; span.Pos.String() != "2:1" || span.End.String() != "2:3" {
+		t.Errorf("span of prefix expression wrong. got=%s-%s", span.Pos, span.End)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Instructions); err != "" {
+			t.Fatalf("testInstructions failed for %s: %s", tt.input, err)
+		}
+
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != "" {
+			t.Fatalf("testConstants failed for %s: %s", tt.input, err)
+		}
+	}
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) string {
+	concatted := concatInstructions(expected)
+
+	if actual.String() != concatted.String() {
+		return "wrong instructions.\nwant=\n" + concatted.String() + "got=\n" + actual.String()
+	}
+	return ""
+}
+
+func testConstants(expected []interface{}, actual []object.Object) string {
+	if len(expected) != len(actual) {
+		return "wrong number of constants"
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				return "constant is not the expected integer: " + actual[i].Inspect()
+			}
+		case float64:
+			float, ok := actual[i].(*object.Float)
+			if !ok || float.Value != constant {
+				return "constant is not the expected float: " + actual[i].Inspect()
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				return "constant is not the expected string: " + actual[i].Inspect()
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return "constant is not a function: " + actual[i].Inspect()
+			}
+			if err := testInstructions(constant, fn.Instructions); err != "" {
+				return err
+			}
+		}
+	}
+	return ""
+}
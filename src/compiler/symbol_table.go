@@ -0,0 +1,101 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Const bool // declared with `const`
+}
+
+// SymbolTable maps names to slots. The outermost table holds the globals and
+// every function literal gets its own enclosed table for its locals.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	names          []string
+	numDefinitions int
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define returns the symbol for name in this table, allocating a new slot
+// unless the name is already defined here. Redefining a name reuses its slot
+// just like a for loop variable rebinds a name in object.Environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	s.numDefinitions++
+	return symbol
+}
+
+// DefineConst defines name like Define and marks the symbol constant. It
+// reports false if name already is a constant in this table.
+func (s *SymbolTable) DefineConst(name string) (Symbol, bool) {
+	symbol := s.Define(name)
+	if symbol.Const {
+		return symbol, false
+	}
+	symbol.Const = true
+	s.store[name] = symbol
+	return symbol, true
+}
+
+// Resolve looks name up from this table outwards. depth is the number of
+// enclosing functions between the use and the definition of a local symbol,
+// and always 0 for globals.
+func (s *SymbolTable) Resolve(name string) (symbol Symbol, depth int, ok bool) {
+	for table := s; table != nil; table = table.Outer {
+		symbol, ok = table.store[name]
+		if ok && symbol.Scope == GlobalScope {
+			return symbol, 0, true
+		}
+		if ok {
+			return symbol, depth, true
+		}
+		if table.Outer != nil && table.Outer.Outer != nil {
+			depth++
+		}
+	}
+	return Symbol{}, 0, false
+}
+
+// Global returns the outermost table.
+func (s *SymbolTable) Global() *SymbolTable {
+	table := s
+	for table.Outer != nil {
+		table = table.Outer
+	}
+	return table
+}
+
+// Names returns the defined names indexed by slot.
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
@@ -0,0 +1,81 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	b := global.Define("b")
+	again := global.Define("a")
+
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("a wrong. got=%+v", a)
+	}
+	if b != (Symbol{Name: "b", Scope: GlobalScope, Index: 1}) {
+		t.Errorf("b wrong. got=%+v", b)
+	}
+	if again != a {
+		t.Errorf("redefining a must reuse its slot. got=%+v", again)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	c := local.Define("c")
+	if c != (Symbol{Name: "c", Scope: LocalScope, Index: 0}) {
+		t.Errorf("c wrong. got=%+v", c)
+	}
+}
+
+func TestDefineConst(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	a, ok := global.DefineConst("a")
+	if !ok || a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0, Const: true}) {
+		t.Errorf("a wrong. got=%+v, ok=%t", a, ok)
+	}
+	if resolved, _, _ := global.Resolve("a"); !resolved.Const {
+		t.Errorf("a is not resolved as a constant. got=%+v", resolved)
+	}
+	if _, ok := global.DefineConst("a"); ok {
+		t.Errorf("redefining constant a must fail")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	tests := []struct {
+		name          string
+		expected      Symbol
+		expectedDepth int
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}, 0},
+		{"b", Symbol{Name: "b", Scope: LocalScope, Index: 0}, 1},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}, 0},
+	}
+
+	for _, tt := range tests {
+		symbol, depth, ok := second.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if symbol != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, symbol)
+		}
+		if depth != tt.expectedDepth {
+			t.Errorf("expected %s at depth %d, got=%d", tt.name, tt.expectedDepth, depth)
+		}
+	}
+
+	if _, _, ok := second.Resolve("d"); ok {
+		t.Errorf("d must not be resolvable")
+	}
+}
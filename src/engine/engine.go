@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"ast"
+	"compiler"
+	"evaluator"
+	"fmt"
+	"object"
+	"vm"
+)
+
+// Names of the available engines.
+const (
+	Eval = "eval" // tree-walking evaluator.Eval
+	VM   = "vm"   // compiler + vm
+)
+
+// Engine executes programs one after another against state that persists
+// between runs, so later programs see the bindings of earlier ones.
+type Engine interface {
+	// Run returns the value of program, which is an *object.Error if it failed
+	// at runtime. The error is non-nil only if program could not be executed at
+	// all: a *CompileError if it could not be compiled, or another error if the
+	// engine itself failed while running it.
+	Run(program *ast.Program) (object.Object, error)
+	// Define binds name to value in the global scope.
+	Define(name string, value object.Object)
+	// Bound reports whether name is bound in the global scope, which is not
+	// the case for a let of a program that failed or skipped it.
+	Bound(name string) bool
+}
+
+// CompileError is returned by Run when the program could not be compiled.
+type CompileError struct {
+	Err error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("compilation failed: %s", e.Err)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+func New(name string) (Engine, error) {
+	switch name {
+	case Eval:
+		return &evalEngine{env: object.NewEnvironment()}, nil
+	case VM:
+		return &vmEngine{
+			symbolTable: compiler.NewSymbolTable(),
+			constants:   []object.Object{},
+			globals:     make([]object.Object, vm.GlobalsSize),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown engine %q. want %q or %q", name, Eval, VM)
+	}
+}
+
+type evalEngine struct {
+	env *object.Environment
+}
+
+func (e *evalEngine) Run(program *ast.Program) (object.Object, error) {
+	return evaluator.Eval(program, e.env), nil
+}
+
+func (e *evalEngine) Define(name string, value object.Object) {
+	e.env.Set(name, value)
+}
+
+func (e *evalEngine) Bound(name string) bool {
+	_, ok := e.env.Get(name)
+	return ok
+}
+
+type vmEngine struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+func (e *vmEngine) Run(program *ast.Program) (object.Object, error) {
+	comp := compiler.NewWithState(e.symbolTable, e.constants)
+	if err := comp.Compile(program); err != nil {
+		return nil, &CompileError{Err: err}
+	}
+	bytecode := comp.Bytecode()
+	e.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsState(bytecode, e.globals)
+	if err := machine.Run(); err != nil {
+		return nil, fmt.Errorf("executing bytecode failed: %s", err)
+	}
+	return machine.Result(), nil
+}
+
+func (e *vmEngine) Define(name string, value object.Object) {
+	symbol := e.symbolTable.Define(name)
+	e.globals[symbol.Index] = value
+}
+
+func (e *vmEngine) Bound(name string) bool {
+	symbol, _, ok := e.symbolTable.Resolve(name)
+	return ok && symbol.Scope == compiler.GlobalScope && e.globals[symbol.Index] != nil
+}
@@ -0,0 +1,326 @@
+package evaluator
+
+import (
+	"io"
+	"math"
+	"math/big"
+	"object"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stdout is where puts writes. Embedders and tests may replace it.
+var Stdout io.Writer = os.Stdout
+
+var buildin = map[string]*object.Buildin{
+	"puts": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				io.WriteString(Stdout, displayString(arg)+"\n")
+			}
+			return NULL
+		},
+	},
+	"len": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			arg, err := singleArgument("len", args)
+			if err != nil {
+				return err
+			}
+			switch arg := arg.(type) {
+			case *object.String:
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
+			case *object.Range:
+				n := arg.Len()
+				if n > math.MaxInt64 {
+					return &object.BigInteger{Value: new(big.Int).SetUint64(n)}
+				}
+				return &object.Integer{Value: int64(n)}
+			default:
+				return newError(object.TypeError, "not support argument(s) type. %T for %s", arg, "len")
+			}
+		},
+	},
+	"range": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError(object.ArityError, "wrong number of arguments for range. got=%d, want=1..3", len(args))
+			}
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				if _, ok := arg.(*object.BigInteger); ok {
+					return newError(object.ValueError, "range bound out of range: %s", arg.Inspect())
+				}
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError(object.TypeError, "not support argument(s) type. %s for %s", arg.Type(), "range")
+				}
+				bounds[i] = integer.Value
+			}
+
+			// range(stop), range(start, stop) and range(start, stop, step)
+			r := &object.Range{Stop: bounds[0], Step: 1}
+			if len(bounds) > 1 {
+				r.Start, r.Stop = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				r.Step = bounds[2]
+			}
+			if r.Step == 0 {
+				return newError(object.ValueError, "range step must not be zero")
+			}
+			return r
+		},
+	},
+	"int": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			arg, err := singleArgument("int", args)
+			if err != nil {
+				return err
+			}
+			switch arg := arg.(type) {
+			case *object.Integer, *object.BigInteger:
+				return arg
+			case *object.Float:
+				// like Go conversions, int truncates towards zero
+				return floatToInteger("int", math.Trunc(arg.Value))
+			case *object.String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+				if !ok {
+					return newError(object.ValueError, "invalid literal for int: %q", arg.Value)
+				}
+				return object.NewInteger(value)
+			default:
+				return newError(object.TypeError, "not support argument(s) type. %s for %s", arg.Type(), "int")
+			}
+		},
+	},
+	"float": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			arg, err := singleArgument("float", args)
+			if err != nil {
+				return err
+			}
+			switch arg := arg.(type) {
+			case *object.Integer, *object.BigInteger:
+				value, _ := toFloat(arg)
+				return &object.Float{Value: value}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError(object.ValueError, "invalid literal for float: %q", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError(object.TypeError, "not support argument(s) type. %s for %s", arg.Type(), "float")
+			}
+		},
+	},
+	"round": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			// halfway cases round away from zero: round(2.5) is 3, round(-2.5) is -3
+			return roundingBuildin("round", math.Round, args)
+		},
+	},
+	"floor": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			return roundingBuildin("floor", math.Floor, args)
+		},
+	},
+	"first": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			array, err := arrayArgument("first", 1, args)
+			if err != nil {
+				return err
+			}
+			if len(array.Elements) == 0 {
+				return NULL
+			}
+			return array.Elements[0]
+		},
+	},
+	"last": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			array, err := arrayArgument("last", 1, args)
+			if err != nil {
+				return err
+			}
+			if len(array.Elements) == 0 {
+				return NULL
+			}
+			return array.Elements[len(array.Elements)-1]
+		},
+	},
+	"rest": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			array, err := arrayArgument("rest", 1, args)
+			if err != nil {
+				return err
+			}
+			if len(array.Elements) == 0 {
+				return NULL
+			}
+			elements := make([]object.Object, len(array.Elements)-1)
+			copy(elements, array.Elements[1:])
+			return &object.Array{Elements: elements}
+		},
+	},
+	"push": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			array, err := arrayArgument("push", 2, args)
+			if err != nil {
+				return err
+			}
+			// push leaves its argument alone and returns a copy with the element
+			// appended; only index assignment changes an array in place
+			elements := make([]object.Object, len(array.Elements), len(array.Elements)+1)
+			copy(elements, array.Elements)
+			return &object.Array{Elements: append(elements, args[1])}
+		},
+	},
+	"keys": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArgument("keys", 1, args)
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			for _, pair := range hash.SortedPairs() {
+				elements = append(elements, pair.Key)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"values": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArgument("values", 1, args)
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			for _, pair := range hash.SortedPairs() {
+				elements = append(elements, pair.Value)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"has": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArgument("has", 2, args)
+			if err != nil {
+				return err
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError(object.TypeError, "Unusable as hash key: %s", args[1].Type())
+			}
+			_, ok = hash.Get(key)
+			return convertNativeBooleanToObject(ok)
+		},
+	},
+	"delete": &object.Buildin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArgument("delete", 2, args)
+			if err != nil {
+				return err
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError(object.TypeError, "Unusable as hash key: %s", args[1].Type())
+			}
+			// like push, delete leaves its argument untouched and returns a new hash
+			result := object.NewHash()
+			for _, pair := range hash.SortedPairs() {
+				result.Set(pair.Key.(object.Hashable), pair.Value)
+			}
+			result.Delete(key)
+			return result
+		},
+	},
+}
+
+// LookupBuildin returns the buildin function bound to name, if any.
+func LookupBuildin(name string) (*object.Buildin, bool) {
+	fn, ok := buildin[name]
+	return fn, ok
+}
+
+// displayString returns the text puts prints for obj: strings without
+// quotes, everything else as inspected.
+func displayString(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
+}
+
+// singleArgument checks that a buildin got exactly one argument and returns it.
+func singleArgument(name string, args []object.Object) (object.Object, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError(object.ArityError, "wrong number of arguments for %s. got=%d, want=1", name, len(args))
+	}
+	return args[0], nil
+}
+
+// roundingBuildin implements a buildin that rounds a number to an integer
+// with round. Integers are returned unchanged.
+func roundingBuildin(name string, round func(float64) float64, args []object.Object) object.Object {
+	arg, err := singleArgument(name, args)
+	if err != nil {
+		return err
+	}
+	switch arg := arg.(type) {
+	case *object.Integer, *object.BigInteger:
+		return arg
+	case *object.Float:
+		return floatToInteger(name, round(arg.Value))
+	default:
+		return newError(object.TypeError, "not support argument(s) type. %s for %s", arg.Type(), name)
+	}
+}
+
+// floatToInteger converts an integral float to an integer, which is a
+// BigInteger for values outside the int64 range. NaN and the infinities are
+// a ValueError.
+func floatToInteger(name string, value float64) object.Object {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return newError(object.ValueError, "cannot convert %s to INTEGER in %s", (&object.Float{Value: value}).Inspect(), name)
+	}
+	if math.MinInt64 <= value && value < math.MaxInt64 {
+		return &object.Integer{Value: int64(value)}
+	}
+	integer, _ := big.NewFloat(value).Int(nil)
+	return object.NewInteger(integer)
+}
+
+// arrayArgument checks that a buildin got want arguments and that the first one is an array.
+func arrayArgument(name string, want int, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) != want {
+		return nil, newError(object.ArityError, "wrong number of arguments for %s. got=%d, want=%d", name, len(args), want)
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newError(object.TypeError, "not support argument(s) type. %s for %s", args[0].Type(), name)
+	}
+	return array, nil
+}
+
+// hashArgument checks that a buildin got want arguments and that the first one is a hash.
+func hashArgument(name string, want int, args []object.Object) (*object.Hash, *object.Error) {
+	if len(args) != want {
+		return nil, newError(object.ArityError, "wrong number of arguments for %s. got=%d, want=%d", name, len(args), want)
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return nil, newError(object.TypeError, "not support argument(s) type. %s for %s", args[0].Type(), name)
+	}
+	return hash, nil
+}
@@ -4,41 +4,59 @@ import (
 	"ast"
 	"object"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 )
 
 var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-var buildin = map[string]*object.Buildin{
-	"len": &object.Buildin{
-		Fn: func(args ...object.Object) object.Object {
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			default:
-				return newError("not support argument(s) type. %T for %s", arg, "len")
-			}
-		},
-	},
-}
+// MaxCallDepth is the number of nested function calls after which both
+// engines fail with a stack overflow.
+const MaxCallDepth = 10000
+
+// callDepth is the number of calls of Monkey functions in progress.
+var callDepth int
 
 func Eval(node ast.Node, environment *object.Environment) object.Object {
+	result := eval(node, environment)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		// the innermost node that produced the error is the most precise location
+		err.Pos = node.Pos()
+		err.End = node.End()
+	}
+	return result
+}
+func eval(node ast.Node, environment *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
+		shareDeclarations(node, map[string]*ast.LetStatement{}, nil)
 		return evalStatement(node.Statements, environment)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, environment)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node.Statements, environment)
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return IntegerLiteral(node)
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return convertNativeBooleanToObject(node.Value)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		parts, abrupt := evalExpressions(node.Parts, environment)
+		if abrupt != nil {
+			return abrupt
+		}
+		return Interpolate(parts)
 	case *ast.PrefixExpression:
 		return evalPrefixExpression(node, environment)
 	case *ast.InfixExpression:
@@ -47,60 +65,457 @@ func Eval(node ast.Node, environment *object.Environment) object.Object {
 		return evalIfExpression(node, environment)
 	case *ast.ReturnStatement:
 		return &object.ReturnValue{Value: Eval(node.ReturnValue, environment)}
-	case *ast.LetStatement:
+	case *ast.ThrowStatement:
 		val := Eval(node.Value, environment)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
-		environment.Set(node.Name.Value, val)
+		return Throw(val)
+	case *ast.TryExpression:
+		return evalTryExpression(node, environment)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, environment)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, environment)
+	case *ast.ForStatement:
+		return evalForStatement(node, environment)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.LetStatement:
+		return evalLetStatement(node, environment)
 	case *ast.FunctionLiteral:
 		return evalFunction(node, environment)
 	case *ast.Identifier:
 		return evalIdentifierExpression(node, environment)
 	case *ast.CallExpression:
 		return evalCallExpression(node, environment)
+	case *ast.ArrayLiteral:
+		elements, abrupt := evalExpressions(node.Elements, environment)
+		if abrupt != nil {
+			return abrupt
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, environment)
+	case *ast.IndexExpression:
+		return evalIndexExpression(node, environment)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, environment)
 	}
 	return nil
 }
 func evalCallExpression(callExpression *ast.CallExpression, environment *object.Environment) object.Object {
 	function := Eval(callExpression.Function, environment)
 
-	if (isError(function)) {
+	if isAbrupt(function) {
 		return function
 	}
 
-	var parameters []object.Object
+	parameters, abrupt := evalArguments(callExpression.Arguments, environment)
+	if abrupt != nil {
+		return abrupt
+	}
+	if function, ok := function.(*object.Function); ok {
+		// raised at the call site, before the function is entered
+		if err := checkArity(function, len(parameters)); err != nil {
+			return err
+		}
+		if callDepth >= MaxCallDepth {
+			return newError(object.RuntimeError, "stack overflow")
+		}
+	}
+	result := applyFunction(function, parameters)
+	if err, ok := result.(*object.Error); ok {
+		if function, ok := function.(*object.Function); ok {
+			err.Stack = append(err.Stack, object.StackFrame{Function: functionName(function.Name), Pos: callExpression.Pos()})
+		}
+	}
+	return result
+
+}
+// evalExpressions evaluates expressions left to right, stopping at the first
+// error, return, break or continue, which it returns as abrupt.
+func evalExpressions(expressions []ast.Expression, environment *object.Environment) (result []object.Object, abrupt object.Object) {
+	for _, expression := range expressions {
+		evaluated := Eval(expression, environment)
+		if isAbrupt(evaluated) {
+			return nil, evaluated
+		}
+		result = append(result, evaluated)
+	}
+	return result, nil
+}
+// evalArguments evaluates the arguments of a call like evalExpressions and
+// expands spread arguments into the elements of their arrays.
+func evalArguments(arguments []ast.Expression, environment *object.Environment) (result []object.Object, abrupt object.Object) {
+	result = []object.Object{}
+	for _, argument := range arguments {
+		spread, isSpread := argument.(*ast.SpreadExpression)
+		if !isSpread {
+			evaluated := Eval(argument, environment)
+			if isAbrupt(evaluated) {
+				return nil, evaluated
+			}
+			result = append(result, evaluated)
+			continue
+		}
+
+		evaluated := Eval(spread.Value, environment)
+		if isAbrupt(evaluated) {
+			return nil, evaluated
+		}
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			return nil, SpreadError(evaluated)
+		}
+		result = append(result, array.Elements...)
+	}
+	return result, nil
+}
+// SpreadError reports a spread argument that is not an array.
+func SpreadError(value object.Object) *object.Error {
+	return newError(object.TypeError, "Spread argument must be ARRAY. got=%s", value.Type())
+}
+func evalIndexExpression(indexExpression *ast.IndexExpression, environment *object.Environment) object.Object {
+	left := Eval(indexExpression.Left, environment)
+	if isAbrupt(left) {
+		return left
+	}
+	index := Eval(indexExpression.Index, environment)
+	if isAbrupt(index) {
+		return index
+	}
+
+	return EvalIndex(left, index)
+}
+// EvalIndex implements `left[index]` on already evaluated operands.
+func EvalIndex(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left.(*object.Array), index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left.(*object.String), index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left.(*object.Hash), index)
+	default:
+		return newError(object.TypeError, "Unsupported index operator: %s[%s]", left.Type(), index.Type())
+	}
+}
+func evalArrayIndexExpression(array *object.Array, index object.Object) object.Object {
+	i, err := arrayIndex(index, len(array.Elements))
+	if err != nil {
+		return err
+	}
+	return array.Elements[i]
+}
+// evalStringIndexExpression returns the character at index of str as a
+// string. Strings are indexed by character, not by byte.
+func evalStringIndexExpression(str *object.String, index object.Object) object.Object {
+	runes := []rune(str.Value)
+	i, err := arrayIndex(index, len(runes))
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: string(runes[i])}
+}
+// arrayIndex checks an integer index into an array of length elements.
+// Negative indices count from the end: a[-1] is the last element.
+func arrayIndex(index object.Object, length int) (int64, *object.Error) {
+	integer, ok := index.(*object.Integer)
+	if ok {
+		i := integer.Value
+		if i < 0 {
+			i += int64(length)
+		}
+		if 0 <= i && i < int64(length) {
+			return i, nil
+		}
+	}
+	// a BigInteger is out of range of any array
+	return 0, newError(object.IndexError, "Index out of range: %s (length %d)", index.Inspect(), length)
+}
+// Interpolate joins the evaluated parts of an interpolated string. Parts are
+// converted to text the way puts prints them.
+func Interpolate(parts []object.Object) *object.String {
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(displayString(part))
+	}
+	return &object.String{Value: out.String()}
+}
+// IntegerLiteral returns the value of an integer literal.
+func IntegerLiteral(literal *ast.IntegerLiteral) object.Object {
+	if literal.Big != nil {
+		return &object.BigInteger{Value: literal.Big}
+	}
+	return &object.Integer{Value: literal.Value}
+}
+func evalHashLiteral(hashLiteral *ast.HashLiteral, environment *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range hashLiteral.Pairs {
+		key := Eval(pair.Key, environment)
+		if isAbrupt(key) {
+			return key
+		}
+		value := Eval(pair.Value, environment)
+		if isAbrupt(value) {
+			return value
+		}
 
-	for _, argmentExpression := range callExpression.Arguments {
-		evaluated := Eval(argmentExpression, environment)
-		if (isError(evaluated)) {
-			return evaluated;
+		if err := SetHashPair(hash, key, value); err != nil {
+			return err
 		}
-		parameters = append(parameters, evaluated)
 	}
-	return applyFunction(function, parameters, environment)
 
+	return hash
+}
+// SetHashPair stores value under key, failing if key is not hashable.
+func SetHashPair(hash *object.Hash, key object.Object, value object.Object) *object.Error {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return newError(object.TypeError, "Unusable as hash key: %s", key.Type())
+	}
+	hash.Set(hashable, value)
+	return nil
+}
+func evalHashIndexExpression(hash *object.Hash, index object.Object) object.Object {
+	hashable, ok := index.(object.Hashable)
+	if !ok {
+		return newError(object.TypeError, "Unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hash.Get(hashable)
+	if !ok {
+		return NULL
+	}
+	return pair.Value
+}
+func evalSliceExpression(sliceExpression *ast.SliceExpression, environment *object.Environment) object.Object {
+	left := Eval(sliceExpression.Left, environment)
+	if isAbrupt(left) {
+		return left
+	}
+
+	var low, high object.Object
+	if sliceExpression.Low != nil {
+		low = Eval(sliceExpression.Low, environment)
+		if isAbrupt(low) {
+			return low
+		}
+	}
+	if sliceExpression.High != nil {
+		high = Eval(sliceExpression.High, environment)
+		if isAbrupt(high) {
+			return high
+		}
+	}
+
+	return EvalSlice(left, low, high)
+}
+// EvalSlice implements `left[low:high]`; low and high are nil when omitted.
+// Strings are sliced by character, not by byte.
+func EvalSlice(left object.Object, low object.Object, high object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		lowIndex, highIndex, err := sliceBounds(low, high, int64(len(left.Elements)))
+		if err != nil {
+			return err
+		}
+		elements := make([]object.Object, highIndex-lowIndex)
+		copy(elements, left.Elements[lowIndex:highIndex])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		lowIndex, highIndex, err := sliceBounds(low, high, int64(len(runes)))
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: string(runes[lowIndex:highIndex])}
+	default:
+		return newError(object.TypeError, "Unsupported slice operator: %s[:]", left.Type())
+	}
+}
+// sliceBounds converts the optional bounds of a slice of a sequence of length
+// elements to indices with low <= high.
+func sliceBounds(low object.Object, high object.Object, length int64) (int64, int64, *object.Error) {
+	lowIndex, err := sliceBound(low, 0, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	highIndex, err := sliceBound(high, length, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	if lowIndex > highIndex {
+		lowIndex = highIndex
+	}
+	return lowIndex, highIndex, nil
 }
-func applyFunction(function object.Object, arguments []object.Object, environment *object.Environment) object.Object {
-	enclosingEnvironment := object.NewEnclosingEnvironment(environment)
+// sliceBound converts an optional slice bound to an index. Negative values
+// count from the end and the result is clamped to [0, length].
+func sliceBound(bound object.Object, fallback int64, length int64) (int64, *object.Error) {
+	if bound == nil {
+		return fallback, nil
+	}
+	if bound, ok := bound.(*object.BigInteger); ok {
+		// beyond either end of any sequence
+		if bound.Value.Sign() < 0 {
+			return 0, nil
+		}
+		return length, nil
+	}
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError(object.TypeError, "Slice bound must be INTEGER. got=%s", bound.Type())
+	}
 
+	value := integer.Value
+	if value < 0 {
+		value += length
+	}
+	if value < 0 {
+		value = 0
+	}
+	if value > length {
+		value = length
+	}
+	return value, nil
+}
+func applyFunction(function object.Object, arguments []object.Object) object.Object {
 	switch function := function.(type) {
 	case *object.Function:
-		for i, argument := range arguments {
-			enclosingEnvironment.Set(function.Parameters[i].Value, argument)
+		callDepth++
+		defer func() { callDepth-- }()
+
+		// The call frame encloses the environment the function was defined in,
+		// not the caller's, so free variables resolve lexically.
+		enclosingEnvironment := object.NewEnclosingEnvironment(function.Environment)
+		for _, name := range localNames(function.Literal) {
+			enclosingEnvironment.Reserve(name)
+		}
+		if err := bindArguments(function, arguments, enclosingEnvironment); err != nil {
+			return err
 		}
-		return Eval(function.Body, enclosingEnvironment)
+		return unwrapReturnValue(Eval(function.Body, enclosingEnvironment))
 	case *object.Buildin:
 		return function.Fn(arguments...)
 	default:
-		return newError("not a function: %s", function.Type())
+		return newError(object.TypeError, "not a function: %s", function.Type())
+	}
+}
+// localNamesCache holds the result of localNames for each function literal
+// called so far.
+var localNamesCache = map[*ast.FunctionLiteral][]string{}
+
+// localNames returns the names a call of literal binds in its own
+// environment: the parameters and the names bound by let statements, for
+// loops and catch clauses in the default values and the body, not counting
+// nested function literals. They are reserved when the call starts, as the
+// compiler allocates their slots, so that a name refers to the same binding
+// in the whole body.
+func localNames(literal *ast.FunctionLiteral) []string {
+	if names, ok := localNamesCache[literal]; ok {
+		return names
+	}
+
+	var names []string
+	for _, parameter := range literal.Parameters {
+		names = append(names, parameter.Value)
+	}
+	collect := func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			names = append(names, node.Name.Value)
+		case *ast.ForStatement:
+			names = append(names, node.Variable.Value)
+		case *ast.TryExpression:
+			if node.Catch != nil {
+				names = append(names, node.Parameter.Value)
+			}
+		}
+		return true
+	}
+	for _, value := range literal.Defaults {
+		if value != nil {
+			ast.Inspect(value, collect)
+		}
+	}
+	ast.Inspect(literal.Body, collect)
+
+	localNamesCache[literal] = names
+	return names
+}
+func checkArity(function *object.Function, got int) *object.Error {
+	least, most := function.Literal.Arity()
+	if got < least || most >= 0 && got > most {
+		return ArityError(functionName(function.Name), least, most, got)
+	}
+	return nil
+}
+// bindArguments binds the parameters of function to arguments, whose number
+// checkArity accepted, in environment. Missing arguments take the default
+// values, which are evaluated left to right in environment so they can refer
+// to earlier parameters, and a rest parameter gets an array of the remaining
+// arguments.
+func bindArguments(function *object.Function, arguments []object.Object, environment *object.Environment) object.Object {
+	literal := function.Literal
+	for i, parameter := range function.Parameters {
+		switch {
+		case literal.Variadic && i == len(function.Parameters)-1:
+			rest := []object.Object{}
+			if i < len(arguments) {
+				rest = append(rest, arguments[i:]...)
+			}
+			environment.Declare(parameter.Value, &object.Array{Elements: rest}, parameter)
+		case i < len(arguments):
+			environment.Declare(parameter.Value, arguments[i], parameter)
+		default:
+			value := Eval(literal.Default(i), environment)
+			if isAbrupt(value) {
+				return value
+			}
+			environment.Declare(parameter.Value, value, parameter)
+		}
+	}
+	return nil
+}
+// ArityError reports a call of the function name with got arguments when it
+// takes from least to most of them, or any number from least if most is -1.
+func ArityError(name string, least int, most int, got int) *object.Error {
+	want := fmt.Sprintf("%d", least)
+	switch {
+	case most < 0:
+		want += ".."
+	case most != least:
+		want += fmt.Sprintf("..%d", most)
+	}
+	return newError(object.ArityError, "wrong number of arguments for %s. got=%d, want=%s", name, got, want)
+}
+// functionName is the name a function is shown with in stack traces.
+func functionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+func unwrapReturnValue(result object.Object) object.Object {
+	if returnValue, ok := result.(*object.ReturnValue); ok {
+		return returnValue.Value
 	}
+	return result
 }
 func evalFunction(literal *ast.FunctionLiteral, environment *object.Environment) object.Object {
 	return &object.Function{
+		Name:        literal.Name,
 		Body:        literal.Body,
 		Parameters:  literal.Parameters,
 		Environment: environment,
+		Literal:     literal,
 	}
 }
 func evalIdentifierExpression(identifier *ast.Identifier, environment *object.Environment) object.Object {
@@ -113,7 +528,7 @@ func evalIdentifierExpression(identifier *ast.Identifier, environment *object.En
 	if value, ok := buildin[identifier.Value]; ok {
 		return value
 	}
-	return newError("Identifier not found: %s", identifier)
+	return newError(object.NameError, "Identifier not found: %s", identifier)
 }
 func evalBlockStatement(statements []ast.Statement, environment *object.Environment) object.Object {
 	var result object.Object
@@ -126,75 +541,398 @@ func evalBlockStatement(statements []ast.Statement, environment *object.Environm
 			return result
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return result
 		}
 	}
 
+	if result == nil {
+		// empty, or ending with a statement that has no value
+		return NULL
+	}
 	return result
 }
-func evalIfExpression(ifExpression *ast.IfExpression, environment *object.Environment) object.Object {
-	condition := Eval(ifExpression.Condition, environment)
-
-	var ret object.Object
+// evalLetStatement binds the value of a let or const statement. A name
+// declared by another let or const statement or by a parameter cannot be
+// declared again in the same environment, that of the enclosing function call.
+func evalLetStatement(letStatement *ast.LetStatement, environment *object.Environment) object.Object {
+	name := letStatement.Name.Value
+	var decl ast.Node = letStatement
+	if shared, ok := sharedDeclarations[letStatement]; ok {
+		decl = shared
+	}
+	if err := checkRedeclaration(name, decl, environment); err != nil {
+		return err
+	}
 
-	if condition == TRUE {
-		ret = Eval(ifExpression.Consequence, environment)
-	} else if ifExpression.Alternative != nil {
-		ret = Eval(ifExpression.Alternative, environment)
+	val := Eval(letStatement.Value, environment)
+	if isAbrupt(val) {
+		return val
+	}
+	if letStatement.IsConst() {
+		environment.SetConst(name, val, decl)
 	} else {
-		ret = NULL
+		environment.Declare(name, val, decl)
 	}
+	return nil
+}
 
-	if isError(ret) {
-		return ret
+// sharedDeclarations maps a let statement in a branch of an if expression to
+// the let statement of the same name in another branch, whose declaration it
+// shares. Only one branch runs, but a loop may run one and then the other
+// without redeclaring the name.
+var sharedDeclarations = map[*ast.LetStatement]*ast.LetStatement{}
+
+// shareDeclarations fills sharedDeclarations for the let statements inside
+// node. declared holds the let statement that declares each name before node
+// in the same function, and siblings those of the branches that node excludes.
+func shareDeclarations(node ast.Node, declared, siblings map[string]*ast.LetStatement) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			locals := map[string]*ast.LetStatement{}
+			for _, value := range node.Defaults {
+				if value != nil {
+					shareDeclarations(value, locals, nil)
+				}
+			}
+			shareDeclarations(node.Body, locals, nil)
+			return false
+		case *ast.IfExpression:
+			shareDeclarations(node.Condition, declared, siblings)
+			consequence := copyDeclarations(declared)
+			shareDeclarations(node.Consequence, consequence, siblings)
+			alternative := copyDeclarations(declared)
+			if node.Alternative != nil {
+				others := copyDeclarations(siblings)
+				for name, let := range consequence {
+					if _, ok := declared[name]; !ok {
+						others[name] = let
+					}
+				}
+				shareDeclarations(node.Alternative, alternative, others)
+			}
+			for name, let := range consequence {
+				declared[name] = let
+			}
+			for name, let := range alternative {
+				if _, ok := declared[name]; !ok {
+					declared[name] = let
+				}
+			}
+			return false
+		case *ast.LetStatement:
+			name := node.Name.Value
+			if _, ok := declared[name]; ok {
+				break
+			}
+			if sibling, ok := siblings[name]; ok {
+				sharedDeclarations[node] = sibling
+				declared[name] = sibling
+			} else {
+				declared[name] = node
+			}
+		}
+		return true
+	})
+}
+
+func copyDeclarations(declared map[string]*ast.LetStatement) map[string]*ast.LetStatement {
+	copied := make(map[string]*ast.LetStatement, len(declared))
+	for name, let := range declared {
+		copied[name] = let
+	}
+	return copied
+}
+// checkRedeclaration returns an error if name, about to be bound by decl, was
+// declared in environment by another statement or parameter. A nil decl, as
+// for loop variables and catch parameters, only conflicts with a constant.
+func checkRedeclaration(name string, decl ast.Node, environment *object.Environment) *object.Error {
+	if previous, ok := environment.ConstDeclaration(name); ok && previous != decl {
+		return newError(object.TypeError, "cannot redeclare constant %s", name)
 	}
+	if previous, ok := environment.Declaration(name); ok && previous != decl && decl != nil {
+		return newError(object.TypeError, "cannot redeclare variable %s", name)
+	}
+	return nil
+}
+// evalAssignExpression assigns to a variable or an element of an array or
+// hash and evaluates to the assigned value. For compound assignments like +=
+// the target is read before the value is evaluated.
+func evalAssignExpression(assign *ast.AssignExpression, environment *object.Environment) object.Object {
+	switch target := assign.Target.(type) {
+	case *ast.Identifier:
+		if environment.IsConst(target.Value) {
+			return newError(object.TypeError, "cannot assign to constant %s", target.Value)
+		}
+		var current object.Object
+		if assign.Operator != "=" {
+			current = evalIdentifierExpression(target, environment)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+		value := evalAssignedValue(assign, current, environment)
+		if isAbrupt(value) {
+			return value
+		}
+		if !environment.Assign(target.Value, value) {
+			return newError(object.NameError, "Cannot assign to undeclared variable: %s", target.Value)
+		}
+		return value
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, environment)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(target.Index, environment)
+		if isAbrupt(index) {
+			return index
+		}
+		var current object.Object
+		if assign.Operator != "=" {
+			current = EvalIndex(left, index)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+		value := evalAssignedValue(assign, current, environment)
+		if isAbrupt(value) {
+			return value
+		}
+		if err := SetIndex(left, index, value); err != nil {
+			return err
+		}
+		return value
+
+	default:
+		return newError(object.TypeError, "Cannot assign to %s", assign.Target)
+	}
+}
+// evalAssignedValue evaluates the value of an assignment, combined with the
+// current value of the target for compound assignments.
+func evalAssignedValue(assign *ast.AssignExpression, current object.Object, environment *object.Environment) object.Object {
+	value := Eval(assign.Value, environment)
+	if isAbrupt(value) || assign.Operator == "=" {
+		return value
+	}
+	// += applies + and so on
+	return EvalInfixOperator(strings.TrimSuffix(assign.Operator, "="), current, value)
+}
+// SetIndex implements left[index] = value. Arrays and hashes are changed in
+// place, unlike push and delete which return new ones.
+func SetIndex(left object.Object, index object.Object, value object.Object) *object.Error {
+	switch left := left.(type) {
+	case *object.Array:
+		if index.Type() != object.INTEGER_OBJ {
+			break
+		}
+		i, err := arrayIndex(index, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		left.Elements[i] = value
+		return nil
+	case *object.Hash:
+		return SetHashPair(left, index, value)
+	}
+	return newError(object.TypeError, "Unsupported index assignment: %s[%s]", left.Type(), index.Type())
+}
+func evalWhileStatement(whileStatement *ast.WhileStatement, environment *object.Environment) object.Object {
+	for {
+		condition := Eval(whileStatement.Condition, environment)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !IsTruthy(condition) {
+			return nil
+		}
+
+		if result, done := evalLoopBody(whileStatement.Body, environment); done {
+			return result
+		}
+	}
+}
+// evalForStatement binds each element of the iterable to the loop variable in
+// environment, where it stays bound after the loop, and runs the body.
+func evalForStatement(forStatement *ast.ForStatement, environment *object.Environment) object.Object {
+	iterable := Eval(forStatement.Iterable, environment)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+	iterator, err := NewIterator(iterable)
+	if err != nil {
+		return err
+	}
+
+	for {
+		element, ok := iterator.Next()
+		if !ok {
+			return nil
+		}
+		if err := checkRedeclaration(forStatement.Variable.Value, nil, environment); err != nil {
+			return err
+		}
+		environment.Set(forStatement.Variable.Value, element)
+
+		if result, done := evalLoopBody(forStatement.Body, environment); done {
+			return result
+		}
+	}
+}
+// evalLoopBody runs one iteration of a loop. done reports whether the loop
+// ends here, with result as the outcome of the loop statement.
+func evalLoopBody(body *ast.BlockStatement, environment *object.Environment) (result object.Object, done bool) {
+	switch result := Eval(body, environment).(type) {
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	case *object.Break:
+		return nil, true
+	}
+	return nil, false
+}
+// NewIterator returns an iterator over the elements of an array, the
+// characters of a string, the keys of a hash in the order of SortedPairs or
+// the integers of a range.
+func NewIterator(iterable object.Object) (*object.Iterator, *object.Error) {
+	var elements []object.Object
 
-	return ret
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements = iterable.Elements
+	case *object.String:
+		for _, r := range iterable.Value {
+			elements = append(elements, &object.String{Value: string(r)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.SortedPairs() {
+			elements = append(elements, pair.Key)
+		}
+	case *object.Range:
+		// ranges are walked lazily, they may be long
+		i, n := uint64(0), iterable.Len()
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			if i >= n {
+				return nil, false
+			}
+			// wraps around like the distance Len computes with
+			value := iterable.Start + int64(i)*iterable.Step
+			i++
+			return &object.Integer{Value: value}, true
+		}}, nil
+	default:
+		return nil, newError(object.TypeError, "not iterable: %s", iterable.Type())
+	}
+
+	i := 0
+	return &object.Iterator{Next: func() (object.Object, bool) {
+		if i >= len(elements) {
+			return nil, false
+		}
+		i++
+		return elements[i-1], true
+	}}, nil
+}
+func evalIfExpression(ifExpression *ast.IfExpression, environment *object.Environment) object.Object {
+	condition := Eval(ifExpression.Condition, environment)
+	if isAbrupt(condition) {
+		return condition
+	}
+
+	if IsTruthy(condition) {
+		return Eval(ifExpression.Consequence, environment)
+	} else if ifExpression.Alternative != nil {
+		return Eval(ifExpression.Alternative, environment)
+	}
+	return NULL
 }
 func evalInfixExpression(infixExpression *ast.InfixExpression, environment *object.Environment) object.Object {
+	switch infixExpression.Operator {
+	case "&&", "||":
+		return evalLogicalExpression(infixExpression, environment)
+	}
+
 	left := Eval(infixExpression.Left, environment)
+	if isAbrupt(left) {
+		return left
+	}
 	right := Eval(infixExpression.Right, environment)
+	if isAbrupt(right) {
+		return right
+	}
 
-	if isError(left) {
+	return EvalInfixOperator(infixExpression.Operator, left, right)
+}
+// evalLogicalExpression evaluates && and ||, which only evaluate the right
+// operand if the left one does not decide the result. Like for !, false and
+// null are falsy and all other values truthy; the result is a boolean.
+func evalLogicalExpression(infixExpression *ast.InfixExpression, environment *object.Environment) object.Object {
+	left := Eval(infixExpression.Left, environment)
+	if isAbrupt(left) {
 		return left
 	}
-	if isError(right) {
-		return right
+	if isFalsy(left) == (infixExpression.Operator == "&&") {
+		return convertNativeBooleanToObject(infixExpression.Operator == "||")
 	}
 
+	right := Eval(infixExpression.Right, environment)
+	if isAbrupt(right) {
+		return right
+	}
+	return convertNativeBooleanToObject(!isFalsy(right))
+}
+// isFalsy reports whether ! turns value into true.
+func isFalsy(value object.Object) bool {
+	return value == FALSE || value == NULL
+}
+// EvalInfixOperator applies a binary operator to already evaluated operands.
+func EvalInfixOperator(operator string, left object.Object, right object.Object) object.Object {
 	switch left.(type) {
-	case *object.Integer:
-		return evalInfixIntegerOperator(infixExpression.Operator, left, right)
+	case *object.Integer, *object.BigInteger, *object.Float:
+		return evalInfixNumberOperator(operator, left, right)
 	case *object.Boolean:
-		return evalInfixBooleanOperator(infixExpression.Operator, left, right)
+		return evalInfixBooleanOperator(operator, left, right)
 	case *object.String:
-		return evalInfixStringOperator(infixExpression.Operator, left, right)
+		return evalInfixStringOperator(operator, left, right)
 	default:
-		return newError("Unsupported operator: %s %s %s", left.Type(), infixExpression.Operator, right.Type())
+		return newError(object.TypeError, "Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
+// IsTruthy reports whether a condition selects the consequence of an if or
+// keeps a while loop going. Everything but false and null is truthy, as for
+// ! and the operands of && and ||.
+func IsTruthy(condition object.Object) bool {
+	return !isFalsy(condition)
+}
 func evalInfixStringOperator(operator string, left object.Object, right object.Object) object.Object {
 	leftString, leftOk := left.(*object.String)
 	rightString, rightOk := right.(*object.String)
 	if !(leftOk && rightOk) {
-		return newError("Type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "Type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	switch operator {
 	case "+":
 		return &object.String{Value: leftString.Value + rightString.Value}
 	default:
-		return newError("Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
-func isError(target object.Object) bool {
-	_, ok := target.(*object.Error)
-	return ok
+// isAbrupt reports whether target is an error, or the result of a return,
+// break or continue, that must propagate out of the enclosing expression.
+func isAbrupt(target object.Object) bool {
+	switch target.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
+	}
+	return false
 }
 func evalInfixBooleanOperator(operator string, left object.Object, right object.Object) object.Object {
 	leftBoolean, leftOk := left.(*object.Boolean)
 	rightBoolean, rightOk := right.(*object.Boolean)
 	if !(leftOk && rightOk) {
-		return newError("Type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "Type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftValue := leftBoolean.Value
@@ -206,29 +944,92 @@ func evalInfixBooleanOperator(operator string, left object.Object, right object.
 	case "!=":
 		return convertNativeBooleanToObject(leftValue != rightValue)
 	default:
-		return newError("Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
+// evalInfixNumberOperator applies operator to integers and floats. Two
+// integers give an integer, so 7 / 2 is 3. If either operand is a float the
+// other one is promoted to float and arithmetic gives a float.
+func evalInfixNumberOperator(operator string, left object.Object, right object.Object) object.Object {
+	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+		return evalInfixIntegerOperator(operator, left, right)
+	}
+
+	leftValue, leftOk := toFloat(left)
+	rightValue, rightOk := toFloat(right)
+	if !(leftOk && rightOk) {
+		return newError(object.TypeError, "Type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		return &object.Float{Value: leftValue / rightValue}
+	case "<":
+		return convertNativeBooleanToObject(leftValue < rightValue)
+	case "<=":
+		return convertNativeBooleanToObject(leftValue <= rightValue)
+	case ">":
+		return convertNativeBooleanToObject(leftValue > rightValue)
+	case ">=":
+		return convertNativeBooleanToObject(leftValue >= rightValue)
+	case "==":
+		return convertNativeBooleanToObject(leftValue == rightValue)
+	case "!=":
+		return convertNativeBooleanToObject(leftValue != rightValue)
+	default:
+		return newError(object.TypeError, "Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+// toFloat converts an integer or a float to float64.
+func toFloat(number object.Object) (float64, bool) {
+	switch number := number.(type) {
+	case *object.Integer:
+		return float64(number.Value), true
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(number.Value).Float64()
+		return value, true
+	case *object.Float:
+		return number.Value, true
+	default:
+		return 0, false
+	}
+}
+// evalInfixIntegerOperator computes with int64 as long as the operands and
+// the result fit and falls back to evalInfixBigIntegerOperator otherwise.
 func evalInfixIntegerOperator(operator string, left object.Object, right object.Object) object.Object {
 	leftInteger, leftOk := left.(*object.Integer)
 	rightInteger, rightOk := right.(*object.Integer)
 	if !(leftOk && rightOk) {
-		return newError("Type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return evalInfixBigIntegerOperator(operator, left, right)
 	}
 	leftValue := leftInteger.Value
 	rightValue := rightInteger.Value
 
 	var result int64
+	var overflow bool
 
 	switch operator {
 	case "+":
 		result = leftValue + rightValue
+		overflow = (result > leftValue) != (rightValue > 0)
 	case "-":
 		result = leftValue - rightValue
+		overflow = (result < leftValue) != (rightValue > 0)
 	case "*":
 		result = leftValue * rightValue
+		overflow = leftValue != 0 && (result/leftValue != rightValue || leftValue == -1 && rightValue == math.MinInt64)
 	case "/":
+		if rightValue == 0 {
+			return newError(object.ZeroDivisionError, "division by zero: %d / 0", leftValue)
+		}
 		result = leftValue / rightValue
+		overflow = leftValue == math.MinInt64 && rightValue == -1
 	case "<":
 		return convertNativeBooleanToObject(leftValue < rightValue)
 	case "<=":
@@ -242,32 +1043,79 @@ func evalInfixIntegerOperator(operator string, left object.Object, right object.
 	case "!=":
 		return convertNativeBooleanToObject(leftValue != rightValue)
 	default:
-		return newError("Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+	if overflow {
+		return evalInfixBigIntegerOperator(operator, left, right)
 	}
 	return &object.Integer{Value: result}
 }
+func evalInfixBigIntegerOperator(operator string, left object.Object, right object.Object) object.Object {
+	leftValue, _ := object.BigValue(left)
+	rightValue, _ := object.BigValue(right)
+
+	switch operator {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(leftValue, rightValue))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(leftValue, rightValue))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(leftValue, rightValue))
+	case "/":
+		if rightValue.Sign() == 0 {
+			return newError(object.ZeroDivisionError, "division by zero: %s / 0", leftValue)
+		}
+		// Quo truncates towards zero like int64 division
+		return object.NewInteger(new(big.Int).Quo(leftValue, rightValue))
+	case "<":
+		return convertNativeBooleanToObject(leftValue.Cmp(rightValue) < 0)
+	case "<=":
+		return convertNativeBooleanToObject(leftValue.Cmp(rightValue) <= 0)
+	case ">":
+		return convertNativeBooleanToObject(leftValue.Cmp(rightValue) > 0)
+	case ">=":
+		return convertNativeBooleanToObject(leftValue.Cmp(rightValue) >= 0)
+	case "==":
+		return convertNativeBooleanToObject(leftValue.Cmp(rightValue) == 0)
+	case "!=":
+		return convertNativeBooleanToObject(leftValue.Cmp(rightValue) != 0)
+	default:
+		return newError(object.TypeError, "Unsupported operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
 
 func evalPrefixExpression(prefixExpression *ast.PrefixExpression, environment *object.Environment) object.Object {
 	right := Eval(prefixExpression.Right, environment)
-	if isError(right) {
+	if isAbrupt(right) {
 		return right
 	}
 
-	switch prefixExpression.Operator {
+	return EvalPrefixOperator(prefixExpression.Operator, right)
+}
+// EvalPrefixOperator applies a unary operator to an already evaluated operand.
+func EvalPrefixOperator(operator string, right object.Object) object.Object {
+	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusOperatorExpression(right)
 	default:
-		return newError("Unsupported operator: %s %s", prefixExpression.Operator, right.Type())
+		return newError(object.TypeError, "Unsupported operator: %s %s", operator, right.Type())
 	}
 }
 func evalMinusOperatorExpression(right object.Object) object.Object {
 	switch right := right.(type) {
 	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
 		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
 	default:
-		return newError("Unsupported operator: %s %s", "-", right.Type())
+		return newError(object.TypeError, "Unsupported operator: %s %s", "-", right.Type())
 	}
 }
 func evalBangOperatorExpression(target object.Object) object.Object {
@@ -306,6 +1154,84 @@ func evalStatement(statements []ast.Statement, environment *object.Environment)
 
 	return result
 }
-func newError(message string, argumentTypes ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(message, argumentTypes...)}
+// evalTryExpression evaluates to the value of the try block, or of the catch
+// block if the try block raised an error. The finally block runs in any case
+// and only changes the outcome if it raises an error, returns or leaves a loop.
+func evalTryExpression(tryExpression *ast.TryExpression, environment *object.Environment) object.Object {
+	result := Eval(tryExpression.Block, environment)
+
+	if err, ok := result.(*object.Error); ok && tryExpression.Catch != nil {
+		if redeclared := checkRedeclaration(tryExpression.Parameter.Value, nil, environment); redeclared != nil {
+			result = redeclared
+		} else {
+			environment.Set(tryExpression.Parameter.Value, CaughtValue(err))
+			result = Eval(tryExpression.Catch, environment)
+		}
+	}
+
+	if tryExpression.Finally != nil {
+		finally := Eval(tryExpression.Finally, environment)
+		switch finally.(type) {
+		case *object.ReturnValue, *object.Error, *object.Break, *object.Continue:
+			return finally
+		}
+	}
+
+	return result
+}
+// CaughtValue is the value a catch clause binds for err: a hash with the
+// "kind" and "message" of the error and the "value" passed to throw, or null.
+func CaughtValue(err *object.Error) object.Object {
+	value := err.Value
+	if value == nil {
+		value = NULL
+	}
+
+	kind := err.Kind
+	if kind == "" {
+		kind = object.RuntimeError
+	}
+
+	caught := object.NewHash()
+	SetHashPair(caught, &object.String{Value: "kind"}, &object.String{Value: string(kind)})
+	SetHashPair(caught, &object.String{Value: "message"}, &object.String{Value: err.Message})
+	SetHashPair(caught, &object.String{Value: "value"}, value)
+	return caught
+}
+// Throw returns the error raised by `throw value`. A hash with string "kind"
+// and "message" entries, like the one bound by catch, raises an error of that
+// kind, so caught errors can be thrown again. Any other value raises an
+// object.UserError.
+func Throw(value object.Object) *object.Error {
+	if hash, ok := value.(*object.Hash); ok {
+		kind, hasKind := hashString(hash, "kind")
+		message, hasMessage := hashString(hash, "message")
+		if hasKind && hasMessage {
+			err := &object.Error{Kind: object.ErrorKind(kind), Message: message}
+			if pair, ok := hash.Get(&object.String{Value: "value"}); ok && pair.Value != NULL {
+				err.Value = pair.Value
+			}
+			return err
+		}
+	}
+
+	message := value.Inspect()
+	if str, ok := value.(*object.String); ok {
+		message = str.Value
+	}
+	return &object.Error{Kind: object.UserError, Message: message, Value: value}
+}
+func hashString(hash *object.Hash, key string) (string, bool) {
+	pair, ok := hash.Get(&object.String{Value: key})
+	if !ok {
+		return "", false
+	}
+	str, ok := pair.Value.(*object.String)
+	if !ok {
+		return "", false
+	}
+	return str.Value, true
+}
+func newError(kind object.ErrorKind, message string, argumentTypes ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(message, argumentTypes...)}
 }
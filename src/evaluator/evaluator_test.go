@@ -61,6 +61,50 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 <= 2`, "true"},
+		{`2 <= 2`, "true"},
+		{`3 <= 2`, "false"},
+		{`2 >= 3`, "false"},
+		{`3 >= 3`, "true"},
+		{`1.5 <= 1`, "false"},
+		{`2 >= 1.5`, "true"},
+		{`9223372036854775807 * 2 >= 9223372036854775807`, "true"},
+		{`"a" <= "b"`, "ERROR: Unsupported operator: STRING <= STRING"},
+		{`true && true`, "true"},
+		{`true && false`, "false"},
+		{`false || true`, "true"},
+		{`false || false`, "false"},
+		{`1 && "a"`, "true"},
+		{`null || 0`, "true"},
+		{`null && 1`, "false"},
+		{`false || null`, "false"},
+		{`false && 1 + true`, "false"},
+		{`true || 1 + true`, "true"},
+		{`true && 1 + true`, "ERROR: Type mismatch: INTEGER + BOOLEAN"},
+		{`let n = 0; let inc = fn() { n += 1; true }; false && inc(); true || inc(); true && inc(); n`, "1"},
+		{`let x = 5; if (x >= 1 && x <= 10) { "in" } else { "out" }`, `"in"`},
+		// if, while, && and || share one notion of truthiness
+		{`if (1) { "yes" } else { "no" }`, `"yes"`},
+		{`if (0) { "yes" } else { "no" }`, `"yes"`},
+		{`if ("") { "yes" } else { "no" }`, `"yes"`},
+		{`if (null) { "yes" } else { "no" }`, `"no"`},
+		{`if (1 && true) { "yes" } else { "no" }`, `"yes"`},
+		{`let n = 3; let i = 0; while (n) { i += 1; n = if (n > 1) { n - 1 } }; i`, "3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestEvalIfExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -118,6 +162,8 @@ func TestErrorHandling(t *testing.T) {
 		{"true + false", "ERROR: Unsupported operator: BOOLEAN + BOOLEAN"},
 		{"5; true + false; 5;", "ERROR: Unsupported operator: BOOLEAN + BOOLEAN"},
 		{"if (true){ true + false; }", "ERROR: Unsupported operator: BOOLEAN + BOOLEAN"},
+		{"if (foobar) { 1 } else { 2 }", "ERROR: Identifier not found: foobar"},
+		{"while (foobar) { 1 }", "ERROR: Identifier not found: foobar"},
 		{"true + true + true", "ERROR: Unsupported operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "ERROR: Identifier not found: foobar"},
 	}
@@ -161,6 +207,34 @@ func TestNull(t *testing.T) {
 	}
 }
 
+func TestBlocksWithoutValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() {}()", "NULL"},
+		{"[fn() {}()]", "[NULL]"},
+		{"[fn() { let q = 2 }()]", "[NULL]"},
+		{"[fn() { while (false) {} }()]", "[NULL]"},
+		{"[if (true) { let a = 1 }]", "[NULL]"},
+		{"[try { let a = 1 } catch (e) {}]", "[NULL]"},
+		{`"${fn() {}()}"`, `"NULL"`},
+		{"!fn() {}()", "true"},
+		{"fn() {}() + 1", "ERROR: Unsupported operator: NULL + INTEGER"},
+		{"-fn() {}()", "ERROR: Unsupported operator: - NULL"},
+		{"let h = {}; h[fn() {}()] = 1", "ERROR: Unusable as hash key: NULL"},
+		{"let f = fn() { for (i in []) {} }; f() + 1", "ERROR: Unsupported operator: NULL + INTEGER"},
+		{"len(fn() { let q = 2 }())", "ERROR: not support argument(s) type. *object.Null for len"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestEvalInteger(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -249,12 +323,65 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(a, b = 2) { a + b }; f(1)`, "3"},
+		{`let f = fn(a, b = 2) { a + b }; f(1, 5)`, "6"},
+		{`let f = fn(a, b = a * 10) { a + b }; f(1)`, "11"},
+		{`let f = fn(a, b = 2) { [a, b] }; f(1, null)`, "[1, NULL]"},
+		{`let n = 0; let f = fn(a = n) { a }; n = 5; f()`, "5"},
+		{`let f = fn(first, ...rest) { [first, rest] }; f(1, 2, 3)`, "[1, [2, 3]]"},
+		{`let f = fn(first, ...rest) { rest }; f(1)`, "[]"},
+		{`let f = fn(a = 1, ...rest) { [a, rest] }; f()`, "[1, []]"},
+		{`let add = fn(a, b, c) { a + b + c }; let xs = [2, 3]; add(1, ...xs)`, "6"},
+		{`let add = fn(a, b, c) { a + b + c }; add(...[1], 2, ...[3])`, "6"},
+		{`let count = fn(...xs) { len(xs) }; count(...[], ...[1, 2], 3)`, "3"},
+		{`len(...["abc"])`, "3"},
+		{`let f = fn(a, b) { a }; f(1)`, "ERROR: wrong number of arguments for f. got=1, want=2"},
+		{`let f = fn(a, b = 2) { a }; f(1, 2, 3)`, "ERROR: wrong number of arguments for f. got=3, want=1..2"},
+		{`let f = fn(a, ...rest) { a }; f()`, "ERROR: wrong number of arguments for f. got=0, want=1.."},
+		{`fn(a) { a }()`, "ERROR: wrong number of arguments for <anonymous>. got=0, want=1"},
+		{`let f = fn(a, b = a + true) { a }; f(1)`, "ERROR: Type mismatch: INTEGER + BOOLEAN"},
+		{`let f = fn(a) { a }; f(...1)`, "ERROR: Spread argument must be ARRAY. got=INTEGER"},
+		{`fn(a, b = 2, ...rest) { 1 }`, "fn (a, b = 2, ...rest) {\n{1;}\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestClosure(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected int64
 	}{
 		{"let a = 10; let f = fn(){a}; f()", 10},
+		{`
+let newAdder = fn(x) { fn(y) { x + y } };
+let addTwo = newAdder(2);
+addTwo(3);
+`, 5},
+		{`
+let newAdder = fn(x) { return fn(y) { return x + y; }; };
+newAdder(2)(3) + 1;
+`, 6},
+		{`
+let compose = fn(f, g) { fn(x) { g(f(x)) } };
+let inc = fn(x) { x + 1 };
+let double = fn(x) { x * 2 };
+compose(inc, double)(5);
+`, 12},
+		{`
+let outer = fn(a) { fn(b) { fn(c) { a * 100 + b * 10 + c } } };
+outer(1)(2)(3);
+`, 123},
 	}
 
 	for _, tt := range tests {
@@ -287,6 +414,64 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a\"b\\c"`, `"a\"b\\c"`},
+		{`"tab\there\n"`, `"tab\there\n"`},
+		{`"\u{1F600}" + "x"`, `"😀x"`},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
+		{`len("\u{1F600}")`, 1},
+		{`"日本語"[1]`, `"本"`},
+		{`"日本語"[-1]`, `"語"`},
+		{`"héllo"[1:4]`, `"éll"`},
+		{`"héllo"[-2:]`, `"lo"`},
+		{`"héllo"[:100]`, `"héllo"`},
+		{`"abc"[3]`, "ERROR: Index out of range: 3 (length 3)"},
+		{`"abc"["a"]`, "ERROR: Unsupported index operator: STRING[STRING]"},
+		{`"abc"[true:]`, "ERROR: Slice bound must be INTEGER. got=BOOLEAN"},
+		{`let n = 0; for (c in "日本") { n += len(c) }; n`, 2},
+		{`let café = 1; let 変数 = café + 1; let count2 = 変数 * 2; count2`, 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+			continue
+		}
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ann"; let age = 41; "hello ${name}, you are ${age + 1}"`, `"hello Ann, you are 42"`},
+		{`"${1}${2.5}${true}${null}"`, `"12.5trueNULL"`},
+		{`"list: ${[1, "a"]}, hash: ${{"k": 1}}"`, `"list: [1, \"a\"], hash: {\"k\": 1}"`},
+		{`"${"nested ${1 + 1}"}!"`, `"nested 2!"`},
+		{`let f = fn(x) { "<${x}>" }; f("a") + f(1)`, `"<a><1>"`},
+		{`"\${x}"`, `"\${x}"`},
+		{`"a ${1 + true} b"`, "ERROR: Type mismatch: INTEGER + BOOLEAN"},
+		{`"a ${x} b"`, "ERROR: Identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestBuildinFunction(t *testing.T) {
 	tests := [] struct {
 		input    string
@@ -300,3 +485,734 @@ func TestBuildinFunction(t *testing.T) {
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + true", "1:1"},
+		{"let a = 1;\nlet b = a + -true;", "2:13"},
+		{"let f = fn() {\n  foobar\n};\nf()", "2:3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Error object expected but got=%s (%T)\nCase: %s", evaluated, evaluated, tt.input)
+			continue
+		}
+		if errorObj.Pos.String() != tt.expected {
+			t.Errorf("Error position wrong. expected=%s, got=%s\nCase: %s", tt.expected, errorObj.Pos, tt.input)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.ErrorKind
+	}{
+		{"1 + true", object.TypeError},
+		{"-true", object.TypeError},
+		{"foobar", object.NameError},
+		{"first([1], [2])", object.ArityError},
+		{"[1, 2][5]", object.IndexError},
+		{"{}[fn(x) { x }]", object.TypeError},
+		{"1(2)", object.TypeError},
+		{"5 / 0", object.ZeroDivisionError},
+		{"(9223372036854775807 * 2) / 0", object.ZeroDivisionError},
+		{"len()", object.ArityError},
+		{"fn(x) { x }(1, 2)", object.ArityError},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Error object expected but got=%s (%T)\nCase: %s", evaluated, evaluated, tt.input)
+			continue
+		}
+		if errorObj.Kind != tt.expected {
+			t.Errorf("Error kind wrong. expected=%s, got=%s\nCase: %s", tt.expected, errorObj.Kind, tt.input)
+		}
+	}
+}
+
+func TestArithmeticSafety(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`5 / 0`, "ERROR: division by zero: 5 / 0"},
+		{`let a = 1; a /= 0`, "ERROR: division by zero: 1 / 0"},
+		{`(9223372036854775807 + 1) / (1 - 1)`, "ERROR: division by zero: 9223372036854775808 / 0"},
+		{`5 / 0.0`, "Inf"},
+		{`len()`, "ERROR: wrong number of arguments for len. got=0, want=1"},
+		{`len("a", "b")`, "ERROR: wrong number of arguments for len. got=2, want=1"},
+		{`let f = fn(a) { a }; f(1, 2)`, "ERROR: wrong number of arguments for f. got=2, want=1"},
+		{`try { 1 / 0 } catch (e) { e["kind"] }`, `"ZeroDivisionError"`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	input := `let inner = fn(x) { x + true };
+let outer = fn(x) { inner(x) };
+let apply = fn(f) { f(1) };
+apply(outer)`
+
+	evaluated := testEval(input)
+	errorObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("Error object expected but got=%s (%T)", evaluated, evaluated)
+	}
+	if errorObj.Pos.String() != "1:21" || errorObj.End.String() != "1:29" {
+		t.Errorf("Error span wrong. got=%s-%s", errorObj.Pos, errorObj.End)
+	}
+
+	expected := []string{"inner 2:21", "outer 3:21", "apply 4:1"}
+	if len(errorObj.Stack) != len(expected) {
+		t.Fatalf("Stack has wrong length. expected=%d, got=%d (%v)", len(expected), len(errorObj.Stack), errorObj.Stack)
+	}
+	for i, frame := range errorObj.Stack {
+		if got := frame.Function + " " + frame.Pos.String(); got != expected[i] {
+			t.Errorf("Stack[%d] wrong. expected=%q, got=%q", i, expected[i], got)
+		}
+	}
+}
+
+func TestLexicalScope(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// the free variable resolves where the function was defined, not where it is called
+		{`
+let x = 1;
+let f = fn() { x };
+let g = fn(x) { f() };
+g(100);
+`, 1},
+		// parameters shadow outer bindings without modifying them
+		{`
+let x = 1;
+let f = fn(x) { x * 10 };
+f(5) + x;
+`, 51},
+		// a let inside a function body does not leak into the defining scope
+		{`
+let x = 1;
+let f = fn() { let x = 2; x };
+f();
+x;
+`, 1},
+		// closures outlive the call that created them and keep their own bindings
+		{`
+let makeConst = fn(v) { fn() { v } };
+let one = makeConst(1);
+let two = makeConst(2);
+one() * 10 + two();
+`, 12},
+		{`
+let factorial = fn(n) { if (n < 2) { 1 } else { n * factorial(n - 1) } };
+factorial(5);
+`, 120},
+		{`
+let fib = fn(n) {
+	if (n < 2) { return n; }
+	fib(n - 1) + fib(n - 2)
+};
+fib(15);
+`, 610},
+		// a recursive inner function captured by its creator's frame
+		{`
+let sumTo = fn(n) {
+	let loop = fn(i, acc) { if (i > n) { acc } else { loop(i + 1, acc + i) } };
+	loop(1, 0)
+};
+sumTo(10);
+`, 55},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLocalNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// the local names of a function shadow outer bindings in its whole body
+		{"let x = 5; let f = fn() { let y = x; let x = 3; y }; f()", "ERROR: Identifier not found: x"},
+		{"let x = 5; fn() { if (false) { let x = 1 }; x }()", "ERROR: Identifier not found: x"},
+		{"let x = 5; fn() { x = 1; let x = 2; x }()", "ERROR: Cannot assign to undeclared variable: x"},
+		{"let x = 5; fn() { x += 1; let x = 2; x }()", "ERROR: Identifier not found: x"},
+		{"let i = 5; fn() { let a = i; for (i in [1]) {}; a }()", "ERROR: Identifier not found: i"},
+		{"let e = 5; fn() { let a = e; try { throw 1 } catch (e) {}; a }()", "ERROR: Identifier not found: e"},
+		{"let b = 9; fn(a = b, b = 1) { a }()", "ERROR: Identifier not found: b"},
+		{"let x = 5; fn() { let g = fn() { x }; let x = 3; g() }()", "3"},
+		{"let x = 5; fn() { let x = 3; x }() + x", "8"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{"[1, 2, 3][3]", "ERROR: Index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-4]", "ERROR: Index out of range: -4 (length 3)"},
+		{"[1, 2, 3][true]", "ERROR: Unsupported index operator: ARRAY[BOOLEAN]"},
+		{"1[0]", "ERROR: Unsupported index operator: INTEGER[INTEGER]"},
+	}
+
+	for _, tt := range tests {
+		testObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArraySliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"[1, 2, 3, 4][0:100]", "[1, 2, 3, 4]"},
+		{"[1, 2][true:]", "ERROR: Slice bound must be INTEGER. got=BOOLEAN"},
+		{"1[0:1]", "ERROR: Unsupported slice operator: INTEGER[:]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestArrayBuildinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len([])`, 0},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])`, []int64{2, 3}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int64{1}},
+		{`let a = [1]; let b = push(a, 2); a`, []int64{1}},
+		{`let a = [1]; let b = push(a, 2); b`, []int64{1, 2}},
+		{`first(1)`, "ERROR: not support argument(s) type. INTEGER for first"},
+		{`last([1], [2])`, "ERROR: wrong number of arguments for last. got=2, want=1"},
+		{`push([1])`, "ERROR: wrong number of arguments for push. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		testObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// testObject checks evaluated against an expected Go value: int for integers,
+// bool for booleans, nil for NULL, []int64 for integer arrays and string for an error's Inspect().
+func testObject(t *testing.T, input string, evaluated object.Object, expected interface{}) bool {
+	switch expected := expected.(type) {
+	case int:
+		return testIntegerObject(t, evaluated, int64(expected))
+	case bool:
+		return testBooleanObject(t, evaluated, expected)
+	case nil:
+		if evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)\nCase: %s", evaluated, evaluated, input)
+			return false
+		}
+	case []int64:
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T (%+v)\nCase: %s", evaluated, evaluated, input)
+			return false
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d\nCase: %s", len(expected), len(array.Elements), input)
+			return false
+		}
+		for i, expectedElement := range expected {
+			if !testIntegerObject(t, array.Elements[i], expectedElement) {
+				return false
+			}
+		}
+	case string:
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Error object expected but got=%s (%T)\nCase: %s", evaluated, evaluated, input)
+			return false
+		}
+		if errorObj.Inspect() != expected {
+			t.Errorf("Error message is not equal to expected value.\n  Got=%s\n  Expected=%s\n  Case=%s",
+				errorObj.Inspect(), expected, input)
+			return false
+		}
+	default:
+		t.Errorf("type of expected not handled. got=%T", expected)
+		return false
+	}
+	return true
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.Hashable]int64{
+		&object.String{Value: "one"}:   1,
+		&object.String{Value: "two"}:   2,
+		&object.String{Value: "three"}: 3,
+		&object.Integer{Value: 4}:      4,
+		TRUE:                           5,
+		FALSE:                          6,
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Get(expectedKey)
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	expectedInspect := `{false: 6, true: 5, 4: 4, "one": 1, "three": 3, "two": 2}`
+	if result.Inspect() != expectedInspect {
+		t.Errorf("Inspect() wrong. expected=%s, got=%s", expectedInspect, result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"name": "x", 1: true}[1]`, true},
+		// integral floats are the same keys as the equal integers
+		{`{1: 5}[1.0]`, 5},
+		{`{1.0: 5}[1]`, 5},
+		{`{1.5: 5}[1.5]`, 5},
+		{`{1.5: 5}[1]`, nil},
+		{`{100000000000000000000: 5}[1e20]`, 5},
+		{`{"foo": 5}[fn(x) { x }]`, "ERROR: Unusable as hash key: FUNCTION"},
+		{`{[1]: 5}`, "ERROR: Unusable as hash key: ARRAY"},
+		{`{fn(x) { x }: 5}`, "ERROR: Unusable as hash key: FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		testObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestHashBuildinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`keys({"b": 1, "a": 2})`, `["a", "b"]`},
+		{`values({"b": 1, "a": 2})`, `[2, 1]`},
+		{`keys({})`, `[]`},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`delete({"a": 1, "b": 2}, "a")`, `{"b": 2}`},
+		{`let h = {"a": 1}; let g = delete(h, "a"); h`, `{"a": 1}`},
+		{`len({1: "a", 1.0: "b", -0.0: "c", 0: "d"})`, "2"},
+		{`{1: "a", 1.0: "b"}`, `{1: "b"}`},
+		{`has({2: "a"}, 2.0)`, "true"},
+		{`delete({2.0: "a", 3: "b"}, 2)`, `{3: "b"}`},
+		{`has({"a": 1}, [1])`, "ERROR: Unusable as hash key: ARRAY"},
+		{`keys([1])`, "ERROR: not support argument(s) type. ARRAY for keys"},
+		{`delete({})`, "ERROR: wrong number of arguments for delete. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { 1 + true } catch (e) { 2 }`, "2"},
+		{`try { if (nope) { 1 } } catch (e) { "caught" }`, `"caught"`},
+		{`try { 1 + true } catch (e) { e["kind"] }`, `"TypeError"`},
+		{`try { 1 + true } catch (e) { e["message"] }`, `"Type mismatch: INTEGER + BOOLEAN"`},
+		{`try { foobar } catch (e) { e["kind"] }`, `"NameError"`},
+		{`try { first(1) } catch (e) { e["kind"] }`, `"TypeError"`},
+		{`try { [1][3] } catch (e) { e["kind"] }`, `"IndexError"`},
+		{`try { throw "boom"; 1 } catch (e) { e }`, `{"kind": "Error", "message": "boom", "value": "boom"}`},
+		{`try { throw [1, 2] } catch (e) { e["value"][1] }`, "2"},
+		{`try { throw {"kind": "ValueError", "message": "bad"} } catch (e) { e["kind"] }`, `"ValueError"`},
+		{`try { try { 1 + true } catch (e) { throw e } } catch (e) { e["kind"] }`, `"TypeError"`},
+		{`let f = fn() { throw "deep" }; let g = fn() { f() + 1 }; try { g() } catch (e) { e["message"] }`, `"deep"`},
+		{`let a = 0; try { 1 } finally { a = 5 }; a`, "5"},
+		{`let a = 0; try { try { throw 1 } finally { a = 5 } } catch (e) { a }`, "5"},
+		{`try { 1 } finally { 2 }`, "1"},
+		{`try { throw 1 } catch (e) { 2 } finally { 3 }`, "2"},
+		{`let f = fn() { try { return 1; } finally { 2 }; 3 }; f()`, "1"},
+		{`let f = fn() { try { return 1; } finally { return 2; } }; f()`, "2"},
+		{`let f = fn() { try { throw 1 } finally { return 2; } }; f()`, "2"},
+		{`let f = fn() { try { throw 1 } catch (e) { return e["value"] + 1; } }; f()`, "2"},
+		{`try { 1 } finally { throw "finally" }`, "ERROR: finally"},
+		{`try { throw 1 } catch (e) { throw 2 }`, "ERROR: 2"},
+		{`throw "uncaught"`, "ERROR: uncaught"},
+		{`1 + try { throw 1 } catch (e) { 2 }`, "3"},
+		// the right operand is not evaluated once the left one failed
+		{`let n = 0; let f = fn() { n += 1; 1 }; try { 1 / 0 + f() } catch (e) { n }`, "0"},
+		{`let n = 0; let f = fn() { n += 1; 1 }; let t = fn() { throw "x" }; try { t() * f() } catch (e) { n }`, "0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let i = 0; while (i < 5) { i = i + 1 }; i`, "5"},
+		{`let i = 0; while (false) { i = i + 1 }; i`, "0"},
+		{`let i = 0; while (true) { i = i + 1; if (i == 3) { break } }; i`, "3"},
+		{`let i = 0; let n = 0; while (i < 5) { i = i + 1; if (i == 2) { continue }; n = n + i }; n`, "13"},
+		{`let i = 0; while (i < 100000) { i = i + 1 }; i`, "100000"},
+		{`let s = 0; for (x in [1, 2, 3]) { s = s + x }; s`, "6"},
+		{`let s = "-"; for (c in "abc") { s = c + s }; s`, `"cba-"`},
+		{`let s = "-"; for (k in {"b": 1, "a": 2}) { s = s + k }; s`, `"-ab"`},
+		{`let s = 0; for (i in range(5)) { s = s + i }; s`, "10"},
+		{`let a = []; for (i in range(10, 0, -3)) { a = push(a, i) }; a`, "[10, 7, 4, 1]"},
+		{`let a = []; for (i in range(1, 10)) { if (i > 3) { break }; a = push(a, i) }; a`, "[1, 2, 3]"},
+		{`let a = []; for (i in range(5)) { if (i == 1) { continue }; a = push(a, i) }; a`, "[0, 2, 3, 4]"},
+		{`let a = []; for (i in range(3)) { for (j in range(3)) { if (j > i) { break }; a = push(a, j) } }; a`, "[0, 0, 1, 0, 1, 2]"},
+		{`let f = fn() { for (i in range(10)) { if (i == 4) { return i; } }; -1 }; f()`, "4"},
+		{`let f = fn() { while (true) { return 7; } }; f()`, "7"},
+		{`let f = fn() { let n = 0; while (true) { n = n + 1; try { if (n > 3) { break } } finally { n = n * 10 } }; n }; f()`, "110"},
+		{`let n = 0; for (i in range(3)) { try { continue } finally { n = n + 1 } }; n`, "3"},
+		{`for (i in range(3)) { try { throw i } catch (e) { break } }; 1`, "1"},
+		{`let i = 0; for (x in []) { i = 1 }; i`, "0"},
+		// the loop variable and the catch parameter stay bound afterwards
+		{`for (x in [1, 2]) { }; x`, "2"},
+		{`for (i in range(3)) { try { throw i } catch (e) { } }; e["value"]`, "2"},
+		{`let n = 0; for (i in range(-9223372036854775807, 9223372036854775807, 9223372036854775807)) { n += 1 }; n`, "2"},
+		{`let a = []; for (i in range(9223372036854775807, -9223372036854775807, -9223372036854775807)) { a = push(a, i) }; a`, "[9223372036854775807, 0]"},
+		{`len(range(-9223372036854775807, 9223372036854775807))`, "18446744073709551614"},
+		{`for (x in 1) { x }`, "ERROR: not iterable: INTEGER"},
+		{`while (1 + true) { 1 }`, "ERROR: Type mismatch: INTEGER + BOOLEAN"},
+		{`len(range(0, 10, 3))`, "4"},
+		{`range(1, 5)`, "range(1, 5)"},
+		{`range(5, 1, -2)`, "range(5, 1, -2)"},
+		{`range(1, 2, 0)`, "ERROR: range step must not be zero"},
+		{`range("a")`, "ERROR: not support argument(s) type. STRING for range"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = 1; a = 2; a`, "2"},
+		{`let a = 1; a = 2`, "2"},
+		{`let a = 1; let b = 1; a = b = 5; a + b`, "10"},
+		{`let a = 10; a += 5; a -= 3; a *= 2; a /= 4; a`, "6"},
+		{`let s = "a"; s += "b"; s`, `"ab"`},
+		{`let count = 0; let inc = fn() { count += 1 }; inc(); inc(); count`, "2"},
+		{`let counter = fn() { let n = 0; fn() { n = n + 1 } }; let c = counter(); c(); c(); c()`, "3"},
+		{`let a = 1; let f = fn() { let a = 2; a = 3; a }; f() * 10 + a`, "31"},
+		{`let i = 0; let s = 0; while (i < 4) { i += 1; s += i }; s`, "10"},
+		{`let a = [1, 2, 3]; a[0] = 10; a[-1] += 5; a`, "[10, 2, 8]"},
+		{`let a = [1]; let b = a; b[0] = 2; a`, "[2]"},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] *= 10; h`, `{"a": 10, "b": 2}`},
+		{`let h = {}; h[1] = 1; h[true] = 2; len(h)`, "2"},
+		{`let m = [[1, 2], [3, 4]]; m[1][0] = 5; m`, "[[1, 2], [5, 4]]"},
+		{`x = 1`, "ERROR: Cannot assign to undeclared variable: x"},
+		{`let f = fn() { y += 1 }; f()`, "ERROR: Identifier not found: y"},
+		{`let a = [1]; a[1] = 2`, "ERROR: Index out of range: 1 (length 1)"},
+		{`let a = 1; a[0] = 2`, "ERROR: Unsupported index assignment: INTEGER[INTEGER]"},
+		{`let h = {}; h[[1]] = 2`, "ERROR: Unusable as hash key: ARRAY"},
+		{`let a = 1; a += true`, "ERROR: Type mismatch: INTEGER + BOOLEAN"},
+		{`let a = 1; try { a = 2; throw a } catch (e) { a += e["value"] }; a`, "4"},
+		{`let f = fn() { let a = 1; a = if (true) { return 5; }; a }; f()`, "5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	evaluated := testEval("x = 1")
+	if errorObj, ok := evaluated.(*object.Error); !ok || errorObj.Kind != object.NameError {
+		t.Errorf("assignment to undeclared variable is not a NameError. got=%v", evaluated)
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`3.14`, "3.14"},
+		{`1e-9`, "1e-09"},
+		{`-2.5`, "-2.5"},
+		{`0.1 + 0.2`, "0.30000000000000004"},
+		{`1.5 * 2`, "3.0"},
+		{`2 * 1.5`, "3.0"},
+		{`7 / 2`, "3"},
+		{`7 / 2.0`, "3.5"},
+		{`1 / 0.0`, "Inf"},
+		{`1 - 0.5`, "0.5"},
+		{`1 == 1.0`, "true"},
+		{`1.5 != 1.5`, "false"},
+		{`2 < 2.5`, "true"},
+		{`2.5 > 3`, "false"},
+		{`let x = 1; x += 0.5; x`, "1.5"},
+		{`{1.5: "a"}[1.5]`, `"a"`},
+		{`{2.5: 1, 1.5: 2, 10.0: 3}`, "{1.5: 2, 2.5: 1, 10.0: 3}"},
+		{`1.5 + true`, "ERROR: Type mismatch: FLOAT + BOOLEAN"},
+		{`"a" + 1.5`, "ERROR: Type mismatch: STRING + FLOAT"},
+		{`[1, 2][1.0]`, "ERROR: Unsupported index operator: ARRAY[FLOAT]"},
+		{`int(3.99)`, "3"},
+		{`int(-3.99)`, "-3"},
+		{`int(7)`, "7"},
+		{`int(" 42 ")`, "42"},
+		{`int("4.2")`, `ERROR: invalid literal for int: "4.2"`},
+		{`int(1e30)`, "1000000000000000019884624838656"},
+		{`int(1 / 0.0)`, "ERROR: cannot convert Inf to INTEGER in int"},
+		{`int(true)`, "ERROR: not support argument(s) type. BOOLEAN for int"},
+		{`float(2)`, "2.0"},
+		{`float("1e3")`, "1000.0"},
+		{`float("x")`, `ERROR: invalid literal for float: "x"`},
+		{`float()`, "ERROR: wrong number of arguments for float. got=0, want=1"},
+		{`round(2.5)`, "3"},
+		{`round(-2.5)`, "-3"},
+		{`round(2.4)`, "2"},
+		{`round(5)`, "5"},
+		{`floor(2.7)`, "2"},
+		{`floor(-2.5)`, "-3"},
+		{`floor("1")`, "ERROR: not support argument(s) type. STRING for floor"},
+		{`round(1.5, 2)`, "ERROR: wrong number of arguments for round. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`9223372036854775807 + 1`, "9223372036854775808"},
+		{`-9223372036854775807 - 2`, "-9223372036854775809"},
+		{`4294967296 * 4294967296`, "18446744073709551616"},
+		{`let f = fn(n) { if (n < 2) { 1 } else { n * f(n - 1) } }; f(25)`, "15511210043330985984000000"},
+		{`(9223372036854775807 + 1) - 1`, "9223372036854775807"},
+		{`(9223372036854775807 + 1) / 2`, "4611686018427387904"},
+		{`-(-9223372036854775807 - 1)`, "9223372036854775808"},
+		{`(-9223372036854775807 - 1) / -1`, "9223372036854775808"},
+		{`-(9223372036854775807 * 3) / 2`, "-13835058055282163710"},
+		{`9223372036854775807 * 2 > 9223372036854775807`, "true"},
+		{`9223372036854775807 * 2 == 18446744073709551614`, "true"},
+		{`9223372036854775807 * 2 != 18446744073709551615`, "true"},
+		{`9223372036854775807 * 2 == 9223372036854775807 + 9223372036854775807`, "true"},
+		{`(9223372036854775807 + 1) * 0.5`, "4611686018427388000.0"},
+		{`let x = 9223372036854775807; x += 1; x`, "9223372036854775808"},
+		{`{9223372036854775807 + 1: "a"}[9223372036854775807 + 1]`, `"a"`},
+		{`{9223372036854775807 * 2: 1, 1: 2, -9223372036854775807 * 2: 3}`, "{-18446744073709551614: 3, 1: 2, 18446744073709551614: 1}"},
+		{`[1, 2][9223372036854775807 * 2]`, "ERROR: Index out of range: 18446744073709551614 (length 2)"},
+		{`[1, 2, 3][-9223372036854775807 * 2:9223372036854775807 * 2]`, "[1, 2, 3]"},
+		{`18446744073709551616 - 18446744073709551615`, "1"},
+		{`int("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{`float(9223372036854775807 * 2)`, "18446744073709552000.0"},
+		{`round(9223372036854775807 * 2)`, "18446744073709551614"},
+		{`range(9223372036854775807 * 2)`, "ERROR: range bound out of range: 18446744073709551614"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	// results that fit into int64 again are demoted to Integer
+	evaluated := testEval(`(9223372036854775807 + 1) - 1`)
+	if _, ok := evaluated.(*object.Integer); !ok {
+		t.Errorf("result is not *object.Integer. got=%T", evaluated)
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const a = 1; a`, "1"},
+		{`const a = [1]; a[0] = 2; a`, "[2]"},
+		{`const a = 1; let f = fn() { let a = 2; a = 3; a }; f() + a`, "4"},
+		{`let f = fn(x) { const y = x * 2; y }; f(1) + f(2)`, "6"},
+		{`let s = 0; for (i in range(3)) { const d = i * 2; s += d }; s`, "6"},
+		// only one branch of an if runs, also when a loop runs both in turn
+		{`let c = true; if (c) { let a = 1 } else { let a = 2 }; a`, "1"},
+		{`let s = 0; for (i in range(4)) { if (i < 2) { let a = i } else { let a = -i }; s += a }; s`, "-4"},
+		{`let f = fn(n) { if (n > 0) { const a = n } else { if (n < 0) { let a = -n } else { let a = 0 } }; a }; [f(1), f(-2), f(0)]`, "[1, 2, 0]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestConstErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const a = 1; a = 2`, "cannot assign to constant a"},
+		{`const a = 1; a += 2`, "cannot assign to constant a"},
+		{`const a = 1; let f = fn() { a = 2 }; f()`, "cannot assign to constant a"},
+		{`const a = 1; let a = 2`, "cannot redeclare constant a"},
+		{`const a = 1; const a = 1`, "cannot redeclare constant a"},
+		{`let a = 1; const a = 2`, "cannot redeclare variable a"},
+		{`let a = 1; let a = 2`, "cannot redeclare variable a"},
+		{`let a = 1; if (true) { let a = 2 }`, "cannot redeclare variable a"},
+		{`let s = 0; while (s < 2) { if (s == 0) { let a = 1 } else { let a = 2 }; let a = 3; s += 1 }`, "cannot redeclare variable a"},
+		{`let f = fn(a) { let a = 2 }; f(1)`, "cannot redeclare variable a"},
+		{`const x = 1; for (x in [5]) { }`, "cannot redeclare constant x"},
+		{`const e = 1; try { throw 2 } catch (e) { }`, "cannot redeclare constant e"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errorObj.Kind != object.TypeError || errorObj.Message != tt.expected {
+			t.Errorf("wrong error for %s. expected=TypeError: %s, got=%s: %s", tt.input, tt.expected, errorObj.Kind, errorObj.Message)
+		}
+	}
+
+	// the constant keeps its value
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("const a = 1; a = 2")).ParseProgram(), env)
+	if value, _ := env.Get("a"); value.Inspect() != "1" {
+		t.Errorf("constant was reassigned. got=%s", value.Inspect())
+	}
+	env = object.NewEnvironment()
+	Eval(parser.New(lexer.New("const x = 1; for (x in [5]) { }")).ParseProgram(), env)
+	if value, _ := env.Get("x"); value.Inspect() != "1" {
+		t.Errorf("constant was rebound by for. got=%s", value.Inspect())
+	}
+}
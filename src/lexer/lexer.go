@@ -1,34 +1,100 @@
 package lexer
 
-import "token"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"token"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input        string
+	filename     string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune // character at position, 0 at the end of input
+	line         int  // line of ch
+	column       int  // column of ch, counted in characters
+
+	comments bool // whether NextToken returns COMMENT tokens
+
+	// templates holds, for each interpolation of a string being lexed, the
+	// number of braces opened inside it and not closed yet.
+	templates []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+// NewWithFilename creates a Lexer whose token positions report filename.
+func NewWithFilename(filename string, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
+// EmitComments makes NextToken return comments as COMMENT tokens, for tools
+// that preserve them. By default comments are skipped like whitespace.
+func (l *Lexer) EmitComments() {
+	l.comments = true
+}
+
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		// already at EOF; keep reporting the same position
+		return
+	}
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.position = l.readPosition
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
+		l.readPosition += 1
 	} else {
-		l.ch = l.input[l.readPosition]
+		ch, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		l.ch = ch
+		l.readPosition += width
+	}
+	l.column += 1
+}
+
+// atEOF reports whether the lexer has read all of its input. The input may
+// contain NUL characters, so l.ch == 0 alone does not tell.
+func (l *Lexer) atEOF() bool {
+	return l.position >= len(l.input)
+}
+
+// pos returns the position of the current character.
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
 	}
-	l.position = l.readPosition
-	l.readPosition += 1
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	for {
+		l.skipWhitespace()
+
+		begin := l.pos()
+		tok := l.nextToken()
+		tok.Pos = begin
+		tok.End = l.pos()
+		if tok.Type != token.COMMENT || l.comments {
+			return tok
+		}
+	}
+}
 
-	l.skipWhitespace()
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -48,9 +114,9 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newAssignableToken(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newAssignableToken(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -60,20 +126,73 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok = l.newAssignableToken(token.ASTERISK, token.ASTERISK_ASSIGN)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		switch l.peekChar() {
+		case '/':
+			return l.readLineComment()
+		case '*':
+			return l.readBlockComment()
+		}
+		tok = l.newAssignableToken(token.SLASH, token.SLASH_ASSIGN)
+	case '#':
+		if l.position == 0 && l.peekChar() == '!' {
+			// a shebang line like #!/usr/bin/env monkey
+			return l.readLineComment()
+		}
+		tok = l.illegalCharacter()
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		n := len(l.templates)
+		if n > 0 && l.templates[n-1] == 0 {
+			// the end of an interpolation, the string goes on
+			l.templates = l.templates[:n-1]
+			tok = l.readString(token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL)
+			break
+		}
+		if n > 0 {
+			l.templates[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = l.illegalCharacter()
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		tok = l.newTwoCharToken('=', token.LE, token.LT)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		tok = l.newTwoCharToken('=', token.GE, token.GT)
+	case '&':
+		tok = l.newTwoCharToken('&', token.AND, token.ILLEGAL)
+		if tok.Type == token.ILLEGAL {
+			tok = l.illegalCharacter()
+		}
+	case '|':
+		tok = l.newTwoCharToken('|', token.OR, token.ILLEGAL)
+		if tok.Type == token.ILLEGAL {
+			tok = l.illegalCharacter()
+		}
 	case '"':
-		tok = l.readString()
+		tok = l.readString(token.TEMPLATE_HEAD, token.STRING)
 	case 0:
+		if !l.atEOF() {
+			tok = l.illegalCharacter()
+			break
+		}
 		tok.Type = token.EOF
 		tok.Literal = ""
 	case '\n':
@@ -83,10 +202,10 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = l.illegalCharacter()
+			l.readChar()
 		}
 		return tok
 	}
@@ -95,31 +214,136 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+// newAssignableToken returns a token of type operator, or of type assign if
+// the operator is followed by '=' as in +=.
+func (l *Lexer) newAssignableToken(operator token.TokenType, assign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: assign, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(operator, l.ch)
+}
+
+// newTwoCharToken returns a token of type double if the current character is
+// followed by next, as in <= or &&, and a token of type single otherwise.
+func (l *Lexer) newTwoCharToken(next rune, double token.TokenType, single token.TokenType) token.Token {
+	if l.peekChar() == next {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: double, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(single, l.ch)
+}
+
+// illegalCharacter returns an ILLEGAL token for the current character. The
+// Literal of ILLEGAL tokens is a message for the parser to report.
+func (l *Lexer) illegalCharacter() token.Token {
+	if l.ch == utf8.RuneError && l.readPosition-l.position == 1 {
+		return token.Token{Type: token.ILLEGAL, Literal: "invalid UTF-8 encoding"}
+	}
+	return token.Token{Type: token.ILLEGAL, Literal: fmt.Sprintf("illegal character %q", l.ch)}
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// UnterminatedComment is the Literal of the ILLEGAL token for a block comment
+// that is not closed before the end of the input.
+const UnterminatedComment = "unterminated comment"
+
+// readLineComment reads a comment up to the end of the line. The Literal of
+// the token is the text of the comment, including the leading // or #!.
+func (l *Lexer) readLineComment() token.Token {
+	position := l.position
+	for l.ch != '\n' && !l.atEOF() {
+		l.readChar()
+	}
+	return token.Token{Type: token.COMMENT, Literal: l.input[position:l.position]}
+}
+
+// readBlockComment reads a comment from /* to the matching */. Block comments
+// nest, so a block comment can comment out code that contains one.
+func (l *Lexer) readBlockComment() token.Token {
+	position := l.position
+	depth := 0
+	for !l.atEOF() {
+		switch {
+		case l.ch == '/' && l.peekChar() == '*':
+			l.readChar()
+			depth++
+		case l.ch == '*' && l.peekChar() == '/':
+			l.readChar()
+			depth--
+		}
+		l.readChar()
+		if depth == 0 {
+			return token.Token{Type: token.COMMENT, Literal: l.input[position:l.position]}
+		}
+	}
+	return token.Token{Type: token.ILLEGAL, Literal: UnterminatedComment}
+}
+
+// readIdentifier reads an identifier: a letter followed by letters and
+// digits. Letters are '_' and the Unicode letters (categories Lu, Ll, Lt, Lm
+// and Lo), digits are the Unicode decimal digits (category Nd), so count2,
+// café and 変数 are identifiers, and 2x is not.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isUnicodeDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'z' || ch == '_'
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func (l *Lexer) readNumber() string {
+func isUnicodeDigit(ch rune) bool {
+	return isDigit(ch) || ch >= utf8.RuneSelf && unicode.IsDigit(ch)
+}
+
+// readNumber reads an integer like 42 or a float like 3.14, 1e-9 or 2.5E3.
+// The '.' and the exponent only belong to the number if digits follow them,
+// so 1.foo is not a float.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	var tokenType token.TokenType = token.INT
+
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		digits := l.readPosition
+		if digits < len(l.input) && (l.input[digits] == '+' || l.input[digits] == '-') {
+			digits++
+		}
+		if digits < len(l.input) && isDigit(rune(l.input[digits])) {
+			tokenType = token.FLOAT
+			for l.position < digits {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
-func isDigit(ch byte) bool {
+// isDigit reports whether ch is an ASCII digit. Number literals are written
+// with ASCII digits only.
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
@@ -129,24 +353,140 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
-func (l *Lexer) readString() token.Token {
-	l.readChar()
-	begin := l.position
+
+// UnterminatedString is the Literal of the ILLEGAL token for a string that
+// is not closed before the end of the input.
+const UnterminatedString = "unterminated string"
+
+// readString reads a string literal, or the part of one that follows an
+// interpolation. The Literal of the token is its value, with the escape
+// sequences \" \\ \$ \n \t \r and \u{hex code point} replaced. The token
+// is of type interpolation if the string goes on with ${, and of type end if
+// it is closed. A string with an invalid escape sequence, or without its
+// closing quote, is an ILLEGAL token.
+func (l *Lexer) readString(interpolation token.TokenType, end token.TokenType) token.Token {
+	var value strings.Builder
+	problem := ""
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break;
+		switch {
+		case l.atEOF():
+			return token.Token{Type: token.ILLEGAL, Literal: UnterminatedString}
+		case l.ch == '"':
+			if problem != "" {
+				return token.Token{Type: token.ILLEGAL, Literal: problem}
+			}
+			return token.Token{Type: end, Literal: value.String()}
+		case l.ch == '$' && l.peekChar() == '{':
+			l.readChar()
+			l.templates = append(l.templates, 0)
+			if problem != "" {
+				return token.Token{Type: token.ILLEGAL, Literal: problem}
+			}
+			return token.Token{Type: interpolation, Literal: value.String()}
+		case l.ch == '\\':
+			l.readChar()
+			if msg := l.readEscape(&value); msg != "" && problem == "" {
+				problem = msg
+			}
+		default:
+			// copied as is, even if it is not valid UTF-8
+			value.WriteString(l.input[l.position:l.readPosition])
 		}
 	}
-	return token.Token{
-		Type:    token.STRING,
-		Literal: l.input[begin:l.position],
+}
+
+var escapes = map[rune]rune{
+	'"':  '"',
+	'\\': '\\',
+	'$':  '$',
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+}
+
+// readEscape writes the character the escape sequence starting at the
+// current character, after the backslash, stands for to value. It returns a
+// message if the sequence is invalid. The current character is left on the
+// last character of the sequence.
+func (l *Lexer) readEscape(value *strings.Builder) string {
+	if ch, ok := escapes[l.ch]; ok {
+		value.WriteRune(ch)
+		return ""
+	}
+	if l.atEOF() {
+		// let readString report the missing quote
+		return ""
+	}
+	if l.ch != 'u' {
+		return fmt.Sprintf("invalid escape sequence \\%c", l.ch)
+	}
+
+	if l.peekChar() != '{' {
+		return "invalid escape sequence \\u, want \\u{hex code point}"
+	}
+	l.readChar()
+	begin := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[begin:l.readPosition]
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		return "invalid escape sequence \\u, want \\u{hex code point}"
+	}
+	l.readChar()
+
+	code, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(code)) {
+		return fmt.Sprintf("invalid code point \\u{%s}", digits)
 	}
+	value.WriteRune(rune(code))
+	return ""
+}
+
+// Quote returns a string literal whose value is s, using escape sequences
+// for quotes, backslashes and characters that are not printable.
+func Quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '$':
+			if strings.HasPrefix(s[i+1:], "{") {
+				// not an interpolation
+				out.WriteByte('\\')
+			}
+			out.WriteByte('$')
+		default:
+			if unicode.IsPrint(r) {
+				out.WriteRune(r)
+			} else {
+				fmt.Fprintf(&out, "\\u{%X}", r)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
 }
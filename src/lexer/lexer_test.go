@@ -158,7 +158,7 @@ let result = add(five, ten);
 
 func TestNextToken4(t *testing.T) {
 	input := `
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 `
 	tests := []charTest{
@@ -250,3 +250,365 @@ let x = "TEST";
 
 	rangeTests(t, tests, New(input))
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := `let x = 5;
+  "ab" == x;`
+	tests := []struct {
+		expectedType token.TokenType
+		line, column int
+		endColumn    int
+	}{
+		{token.LET, 1, 1, 4},
+		{token.IDENT, 1, 5, 6},
+		{token.ASSIGN, 1, 7, 8},
+		{token.INT, 1, 9, 10},
+		{token.SEMICOLON, 1, 10, 11},
+		{token.STRING, 2, 3, 7},
+		{token.EQ, 2, 8, 10},
+		{token.IDENT, 2, 11, 12},
+		{token.SEMICOLON, 2, 12, 13},
+		{token.EOF, 2, 13, 13},
+	}
+
+	l := NewWithFilename("test.mk", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected %q, got %q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.Filename != "test.mk" {
+			t.Errorf("tests[%d] - filename wrong. got %q", i, tok.Pos.Filename)
+		}
+		if tok.Pos.Line != tt.line || tok.Pos.Column != tt.column {
+			t.Errorf("tests[%d] - position wrong. expected %d:%d, got %s", i, tt.line, tt.column, tok.Pos)
+		}
+		if tok.End.Line != tt.line || tok.End.Column != tt.endColumn {
+			t.Errorf("tests[%d] - end wrong. expected %d:%d, got %s", i, tt.line, tt.endColumn, tok.End)
+		}
+	}
+}
+
+func TestArrayToken(t *testing.T) {
+	input := `[1, 2][0:1];`
+	tests := []charTest{
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+	}
+
+	rangeTests(t, tests, New(input))
+}
+
+func TestFloatToken(t *testing.T) {
+	input := `3.14 1e-9 2.5E3 1e+2 7 2e x`
+	tests := []charTest{
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E3"},
+		{token.FLOAT, "1e+2"},
+		{token.INT, "7"},
+		{token.INT, "2"},
+		{token.IDENT, "e"},
+		{token.IDENT, "x"},
+	}
+
+	rangeTests(t, tests, New(input))
+}
+
+func TestAssignToken(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x+1`
+	tests := []charTest{
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+	}
+
+	rangeTests(t, tests, New(input))
+}
+
+func TestEllipsisToken(t *testing.T) {
+	input := `fn(...rest) { f(...rest) } ..`
+	tests := []charTest{
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.RBRACE, "}"},
+		{token.ILLEGAL, "illegal character '.'"},
+		{token.ILLEGAL, "illegal character '.'"},
+	}
+
+	rangeTests(t, tests, New(input))
+}
+
+func TestLogicalToken(t *testing.T) {
+	input := `a <= b >= c < d && e || f & |`
+	tests := []charTest{
+		{token.IDENT, "a"},
+		{token.LE, "<="},
+		{token.IDENT, "b"},
+		{token.GE, ">="},
+		{token.IDENT, "c"},
+		{token.LT, "<"},
+		{token.IDENT, "d"},
+		{token.AND, "&&"},
+		{token.IDENT, "e"},
+		{token.OR, "||"},
+		{token.IDENT, "f"},
+		{token.ILLEGAL, "illegal character '&'"},
+		{token.ILLEGAL, "illegal character '|'"},
+	}
+
+	rangeTests(t, tests, New(input))
+}
+
+func TestStringEscapes(t *testing.T) {
+	input := `"a\"b" "\\" "\n\t\r" "\u{48}\u{e9}\u{1F600}" "日本"
+"a
+b" @`
+	tests := []charTest{
+		{token.STRING, `a"b`},
+		{token.STRING, `\`},
+		{token.STRING, "\n\t\r"},
+		{token.STRING, "Hé😀"},
+		{token.STRING, "日本"},
+		{token.STRING, "a\nb"},
+		{token.ILLEGAL, "illegal character '@'"},
+	}
+
+	rangeTests(t, tests, New(input))
+}
+
+func TestIllegalString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc`, UnterminatedString},
+		{`"abc\"`, UnterminatedString},
+		{`"abc\`, UnterminatedString},
+		{`"a\qb"`, `invalid escape sequence \q`},
+		{`"\u0041"`, `invalid escape sequence \u, want \u{hex code point}`},
+		{`"\u{}"`, `invalid escape sequence \u, want \u{hex code point}`},
+		{`"\u{41"`, `invalid escape sequence \u, want \u{hex code point}`},
+		{`"\u{1234567}"`, `invalid escape sequence \u, want \u{hex code point}`},
+		{`"\u{D800}"`, `invalid code point \u{D800}`},
+		{`"\u{110000}"`, `invalid code point \u{110000}`},
+	}
+
+	for _, tt := range tests {
+		rangeTests(t, []charTest{{token.ILLEGAL, tt.expected}}, New(tt.input))
+	}
+}
+
+func TestInterpolatedStringToken(t *testing.T) {
+	input := `"hello ${name}, you are ${age + 1}" "${ {"a": "}"}["a"] }" "\${x} $y"`
+	tests := []charTest{
+		{token.TEMPLATE_HEAD, "hello "},
+		{token.IDENT, "name"},
+		{token.TEMPLATE_MIDDLE, ", you are "},
+		{token.IDENT, "age"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.TEMPLATE_TAIL, ""},
+
+		{token.TEMPLATE_HEAD, ""},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.STRING, "}"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, ""},
+
+		{token.STRING, "${x} $y"},
+	}
+
+	rangeTests(t, tests, New(input))
+
+	rangeTests(t, []charTest{
+		{token.TEMPLATE_HEAD, "a"},
+		{token.IDENT, "x"},
+		{token.ILLEGAL, UnterminatedString},
+	}, New(`"a${x}b`))
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"plain", `"plain"`},
+		{"a\"b\\c", `"a\"b\\c"`},
+		{"\n\t\r", `"\n\t\r"`},
+		{"${x} $y", `"\${x} $y"`},
+		{"日本\x00", `"日本\u{0}"`},
+	}
+
+	for _, tt := range tests {
+		quoted := Quote(tt.value)
+		if quoted != tt.expected {
+			t.Errorf("Quote(%q) = %s, want %s", tt.value, quoted, tt.expected)
+		}
+		rangeTests(t, []charTest{{token.STRING, tt.value}}, New(quoted))
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := "count2 café 変数 _x9 Σ1 x٣ 2x"
+	tests := []charTest{
+		{token.IDENT, "count2"},
+		{token.IDENT, "café"},
+		{token.IDENT, "変数"},
+		{token.IDENT, "_x9"},
+		{token.IDENT, "Σ1"},
+		{token.IDENT, "x٣"},
+		{token.INT, "2"},
+		{token.IDENT, "x"},
+	}
+
+	rangeTests(t, tests, New(input))
+}
+
+func TestIllegalCharacters(t *testing.T) {
+	input := "a @ €\n  b \x00 \xff c"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		line, column    int
+		endColumn       int
+	}{
+		{token.IDENT, "a", 1, 1, 2},
+		{token.ILLEGAL, "illegal character '@'", 1, 3, 4},
+		{token.ILLEGAL, "illegal character '€'", 1, 5, 6},
+		{token.IDENT, "b", 2, 3, 4},
+		{token.ILLEGAL, `illegal character '\x00'`, 2, 5, 6},
+		{token.ILLEGAL, "invalid UTF-8 encoding", 2, 7, 8},
+		{token.IDENT, "c", 2, 9, 10},
+		{token.EOF, "", 2, 10, 10},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected %q %q, got %q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Line != tt.line || tok.Pos.Column != tt.column || tok.End.Column != tt.endColumn {
+			t.Errorf("tests[%d] - position wrong. expected %d:%d-%d, got %s-%s",
+				i, tt.line, tt.column, tt.endColumn, tok.Pos, tok.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `#!/usr/bin/env monkey
+let x = 1; // one
+/* a /* nested */ comment */ x / 2 /**/
+# not a shebang`
+	tests := []charTest{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "illegal character '#'"},
+		{token.IDENT, "not"},
+		{token.IDENT, "a"},
+		{token.IDENT, "shebang"},
+	}
+
+	rangeTests(t, tests, New(input))
+
+	l := New(input)
+	l.EmitComments()
+	rangeTests(t, []charTest{
+		{token.COMMENT, "#!/usr/bin/env monkey"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// one"},
+		{token.COMMENT, "/* a /* nested */ comment */"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.COMMENT, "/**/"},
+		{token.ILLEGAL, "illegal character '#'"},
+		{token.IDENT, "not"},
+		{token.IDENT, "a"},
+		{token.IDENT, "shebang"},
+	}, l)
+}
+
+func TestCommentPosition(t *testing.T) {
+	l := New("/* a\n b */ x // c")
+	l.EmitComments()
+
+	expected := []struct {
+		tokenType token.TokenType
+		pos, end  string
+	}{
+		{token.COMMENT, "1:1", "2:6"},
+		{token.IDENT, "2:7", "2:8"},
+		{token.COMMENT, "2:9", "2:13"},
+		{token.EOF, "2:13", "2:13"},
+	}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.tokenType || tok.Pos.String() != tt.pos || tok.End.String() != tt.end {
+			t.Errorf("tests[%d] - expected %s %s-%s, got %s %s-%s",
+				i, tt.tokenType, tt.pos, tt.end, tok.Type, tok.Pos, tok.End)
+		}
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	rangeTests(t, []charTest{
+		{token.IDENT, "x"},
+		{token.ILLEGAL, UnterminatedComment},
+	}, New("x /* a /* b */"))
+}
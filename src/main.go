@@ -1,15 +1,287 @@
 package main
 
-import "fmt"
-import "os"
-import "./repl"
+import (
+	"ast"
+	"checker"
+	"encoding/json"
+	"engine"
+	"evaluator"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"lexer"
+	"object"
+	"os"
+	"parser"
+	"printer"
+	"repl"
+)
+
+// Exit codes of the monkey command.
+const (
+	exitOK           = 0
+	exitRuntimeError = 1 // the program failed while running
+	exitUsage        = 2 // bad command line or unreadable script
+	exitSyntaxError  = 3 // the program could not be parsed or compiled
+	exitUnformatted  = 4 // monkey fmt -check found files that are not formatted
+)
+
+const usage = `Usage:
+  monkey [flags]                       start the REPL
+  monkey [flags] -e 'code' [args...]   run code and print its value
+  monkey [flags] run file.mk [args...] run a script
+  monkey fmt [-check] [-w] [files...]  format scripts, or standard input
+  monkey parse [-json] [file.mk]       print the syntax tree of a script
+
+The program sees the remaining arguments as the array of strings ARGS.
+
+Flags:
+`
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(arguments []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		io.WriteString(stderr, usage)
+		flags.PrintDefaults()
+	}
+	engineName := flags.String("engine", engine.Eval, "execution engine: eval (tree-walking evaluator) or vm (bytecode)")
+	code := flags.String("e", "", "evaluate `code` and print its value")
+
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+	rest := flags.Args()
+
+	isSet := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "e" {
+			isSet = true
+		}
+	})
+
+	switch {
+	case isSet:
+		return execute("-e", *code, rest, *engineName, true, stdout, stderr)
+	case len(rest) == 0:
+		io.WriteString(stdout, "Hello! This is the Monkey programming language!\n")
+		io.WriteString(stdout, "Feel free to type commands\n")
+		repl.StartWithEngine(stdin, stdout, *engineName)
+		return exitOK
+	case rest[0] == "run":
+		if len(rest) < 2 {
+			io.WriteString(stderr, "monkey run: missing script file\n")
+			flags.Usage()
+			return exitUsage
+		}
+		source, err := ioutil.ReadFile(rest[1])
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey run: %s\n", err)
+			return exitUsage
+		}
+		return execute(rest[1], string(source), rest[2:], *engineName, false, stdout, stderr)
+	case rest[0] == "fmt":
+		return format(rest[1:], stdin, stdout, stderr)
+	case rest[0] == "parse":
+		return parse(rest[1:], stdin, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "monkey: unknown command %q\n", rest[0])
+		flags.Usage()
+		return exitUsage
+	}
+}
+
+// execute runs source with args bound to ARGS and reports errors on stderr.
+// If printResult is set the value of the program, unless NULL, is written to stdout.
+func execute(filename string, source string, args []string, engineName string,
+	printResult bool, stdout io.Writer, stderr io.Writer) int {
+
+	runner, err := engine.New(engineName)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %s\n", err)
+		return exitUsage
+	}
+
+	p := parser.New(lexer.NewWithFilename(filename, source))
+	program := p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 {
+		errors = checker.Check(program)
+	}
+	if len(errors) != 0 {
+		for _, msg := range errors {
+			io.WriteString(stderr, msg+"\n")
+		}
+		return exitSyntaxError
+	}
+
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	runner.Define("ARGS", &object.Array{Elements: elements})
+
+	result, err := runProgram(runner, program)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", filename, err)
+		if _, ok := err.(*engine.CompileError); ok {
+			return exitSyntaxError
+		}
+		return exitRuntimeError
+	}
+
+	if errorObj, ok := result.(*object.Error); ok {
+		io.WriteString(stderr, errorObj.Traceback())
+		return exitRuntimeError
+	}
+
+	if printResult && result != nil && result != evaluator.NULL {
+		io.WriteString(stdout, result.Inspect()+"\n")
+	}
+	return exitOK
+}
+
+// runProgram runs program and turns a panic of the engine into an error, so
+// that a bug in the interpreter is reported like any other failure.
+func runProgram(runner engine.Engine, program *ast.Program) (result object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("internal error: %v", r)
+		}
+	}()
+	return runner.Run(program)
+}
+
+// format implements monkey fmt. Formatted scripts are written to stdout, or
+// back to their files with -w. With -check nothing is written; the files that
+// are not formatted are listed and the exit code tells whether there were any.
+func format(arguments []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	check := flags.Bool("check", false, "list files whose formatting differs and fail if there are any")
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		source, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			return exitUsage
+		}
+		formatted, err := printer.Format("<stdin>", source)
+		if err != nil {
+			io.WriteString(stderr, err.Error()+"\n")
+			return exitSyntaxError
+		}
+		if *check {
+			if string(formatted) != string(source) {
+				io.WriteString(stdout, "<stdin>\n")
+				return exitUnformatted
+			}
+			return exitOK
+		}
+		stdout.Write(formatted)
+		return exitOK
+	}
+
+	code := exitOK
+	for _, file := range files {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			return exitUsage
+		}
+		formatted, err := printer.Format(file, source)
+		if err != nil {
+			io.WriteString(stderr, err.Error()+"\n")
+			code = exitSyntaxError
+			continue
+		}
+
+		switch {
+		case *check:
+			if string(formatted) != string(source) {
+				io.WriteString(stdout, file+"\n")
+				if code == exitOK {
+					code = exitUnformatted
+				}
+			}
+		case *write:
+			if string(formatted) != string(source) {
+				if err := ioutil.WriteFile(file, formatted, 0644); err != nil {
+					fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+					return exitUsage
+				}
+			}
+		default:
+			stdout.Write(formatted)
+		}
+	}
+	return code
+}
+
+// parse implements monkey parse, which prints the program in file, or read
+// from stdin, fully parenthesized or, with -json, as the JSON form of its
+// syntax tree including the comments.
+func parse(arguments []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey parse", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the syntax tree as JSON")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+
+	var filename string
+	var source []byte
+	var err error
+	switch flags.NArg() {
+	case 0:
+		filename = "<stdin>"
+		source, err = ioutil.ReadAll(stdin)
+	case 1:
+		filename = flags.Arg(0)
+		source, err = ioutil.ReadFile(filename)
+	default:
+		io.WriteString(stderr, "monkey parse: too many files\n")
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey parse: %s\n", err)
+		return exitUsage
+	}
 
-	panic("TEST")
+	l := lexer.NewWithFilename(filename, string(source))
+	l.EmitComments()
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		for _, msg := range errors {
+			io.WriteString(stderr, msg+"\n")
+		}
+		return exitSyntaxError
+	}
 
-	fmt.Printf("Hello! This is the Monkey programming language!\n")
-	fmt.Printf("Feel free to type commands\n")
+	if !*asJSON {
+		for _, stmt := range program.Statements {
+			io.WriteString(stdout, stmt.String()+"\n")
+		}
+		return exitOK
+	}
 
-	repl.Start(os.Stdin, os.Stdout)
+	encoder := json.NewEncoder(stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(program); err != nil {
+		fmt.Fprintf(stderr, "monkey parse: %s\n", err)
+		return exitUsage
+	}
+	return exitOK
 }
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"ast"
+	"bytes"
+	"encoding/json"
+	"evaluator"
+	"io/ioutil"
 	"log"
+	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +33,206 @@ func TestGetCurrentUser(t *testing.T) {
 		})
 	})
 }
+
+// flagDefaults is what the usage message shows for the flags of monkey.
+const flagDefaults = `  -e code
+    	evaluate code and print its value
+  -engine string
+    	execution engine: eval (tree-walking evaluator) or vm (bytecode) (default "eval")
+`
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.mk")
+	source := `#!/usr/bin/env monkey run
+// greet says hello to each name in turn
+let greet = fn(names) { if (len(names) > 0) { puts("hello " + first(names)); greet(rest(names)) } };
+greet(ARGS); /* ARGS are the arguments after the script */`
+	if err := ioutil.WriteFile(script, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.mk")
+	if err := ioutil.WriteFile(broken, []byte("let x = 1;\nx + true;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arguments      []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"-e", "1 + 2"}, exitOK, "3\n", ""},
+		{[]string{"-engine", "vm", "-e", "1 + 2"}, exitOK, "3\n", ""},
+		{[]string{"-e", "let a = 1;"}, exitOK, "", ""},
+		{[]string{"-e", `puts("hi")`}, exitOK, "hi\n", ""},
+		{[]string{"-e", "len(ARGS)", "a", "b"}, exitOK, "2\n", ""},
+		{[]string{"-e", "let = 1"}, exitSyntaxError, "", "-e:1:5: expected next token to be IDENT, got = instead\n"},
+		{[]string{"-e", "let = 1;\nlet y 2;\nreturn 3"}, exitSyntaxError, "",
+			"-e:1:5: expected next token to be IDENT, got = instead\n-e:2:7: expected next token to be =, got INT instead\n"},
+		{[]string{"-e", "const a = 1;\na = 2"}, exitSyntaxError, "", "-e:2:1: cannot assign to constant a (declared at -e:1:7)\n"},
+		{[]string{"-e", "foo"}, exitRuntimeError, "", "-e:1:1: NameError: Identifier not found: foo\n"},
+		{[]string{"-e", "fn() {}() + 1"}, exitRuntimeError, "", "-e:1:1: TypeError: Unsupported operator: NULL + INTEGER\n"},
+		{[]string{"-engine", "vm", "-e", "fn() {}() + 1"}, exitRuntimeError, "", "-e:1:1: TypeError: Unsupported operator: NULL + INTEGER\n"},
+		{[]string{"run", script, "alice", "bob"}, exitOK, "hello alice\nhello bob\n", ""},
+		{[]string{"-engine", "vm", "run", script, "carol"}, exitOK, "hello carol\n", ""},
+		{[]string{"run", broken}, exitRuntimeError, "", broken + ":2:1: TypeError: Type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{"-engine", "vm", "run", broken}, exitRuntimeError, "", broken + ":2:1: TypeError: Type mismatch: INTEGER + BOOLEAN\n"},
+		{[]string{"-e", "let add = fn(a, b) { a + b };\nlet twice = fn(x) { add(x, x) };\ntwice(true)"}, exitRuntimeError, "",
+			"-e:1:22: TypeError: Unsupported operator: BOOLEAN + BOOLEAN\n    at add (-e:1:22)\n    at twice (-e:2:21)\n    at <main> (-e:3:1)\n"},
+		{[]string{"-engine", "vm", "-e", "let add = fn(a, b) { a + b };\nlet twice = fn(x) { add(x, x) };\ntwice(true)"}, exitRuntimeError, "",
+			"-e:1:22: TypeError: Unsupported operator: BOOLEAN + BOOLEAN\n    at add (-e:1:22)\n    at twice (-e:2:21)\n    at <main> (-e:3:1)\n"},
+		{[]string{"run", filepath.Join(dir, "missing.mk")}, exitUsage, "",
+			"monkey run: open " + filepath.Join(dir, "missing.mk") + ": no such file or directory\n"},
+		{[]string{"run"}, exitUsage, "", "monkey run: missing script file\n" + usage + flagDefaults},
+		{[]string{"-engine", "jit", "-e", "1"}, exitUsage, "", "monkey: unknown engine \"jit\". want \"eval\" or \"vm\"\n"},
+		{[]string{"frobnicate"}, exitUsage, "", "monkey: unknown command \"frobnicate\"\n" + usage + flagDefaults},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		evaluator.Stdout = &stdout
+
+		code := run(tt.arguments, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: exit code wrong. expected=%d, got=%d (stderr=%q)", tt.arguments, tt.expectedCode, code, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: stdout wrong. expected=%q, got=%q", tt.arguments, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%v: stderr wrong. expected=%q, got=%q", tt.arguments, tt.expectedStderr, stderr.String())
+		}
+	}
+	evaluator.Stdout = os.Stdout
+}
+
+func TestFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	formatted := filepath.Join(dir, "formatted.mk")
+	if err := ioutil.WriteFile(formatted, []byte("let x = 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	messy := filepath.Join(dir, "messy.mk")
+	if err := ioutil.WriteFile(messy, []byte("let  x=1\nputs( x )"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arguments      []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"fmt"}, "let  x=1\nputs( x )", exitOK, "let x = 1;\nputs(x);\n", ""},
+		{[]string{"fmt"}, "let = 1", exitSyntaxError, "", "<stdin>:1:5: expected next token to be IDENT, got = instead\n"},
+		{[]string{"fmt", "-check"}, "let x = 1;\n", exitOK, "", ""},
+		{[]string{"fmt", "-check"}, "let x = 1", exitUnformatted, "<stdin>\n", ""},
+		{[]string{"fmt", messy}, "", exitOK, "let x = 1;\nputs(x);\n", ""},
+		{[]string{"fmt", "-check", formatted, messy}, "", exitUnformatted, messy + "\n", ""},
+		{[]string{"fmt", "-w", messy}, "", exitOK, "", ""},
+		{[]string{"fmt", "-check", formatted, messy}, "", exitOK, "", ""},
+		{[]string{"fmt", filepath.Join(dir, "missing.mk")}, "", exitUsage, "",
+			"monkey fmt: open " + filepath.Join(dir, "missing.mk") + ": no such file or directory\n"},
+		{[]string{"fmt", "-x"}, "", exitUsage, "", "flag provided but not defined: -x\nUsage of monkey fmt:\n" +
+			"  -check\n    \tlist files whose formatting differs and fail if there are any\n" +
+			"  -w\twrite the result to the file instead of stdout\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(tt.arguments, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: exit code wrong. expected=%d, got=%d (stderr=%q)", tt.arguments, tt.expectedCode, code, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: stdout wrong. expected=%q, got=%q", tt.arguments, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%v: stderr wrong. expected=%q, got=%q", tt.arguments, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.mk")
+	if err := ioutil.WriteFile(script, []byte("// answer\nlet x = 1 + 2 * 3;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arguments      []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"parse", script}, "", exitOK, "let x = (1 + (2 * 3));\n", ""},
+		{[]string{"parse"}, "a - b; -c", exitOK, "(a - b)\n(-c)\n", ""},
+		{[]string{"parse", "-json"}, "", exitOK, `{
+  "kind": "Program",
+  "filename": "",
+  "pos": null,
+  "end": null,
+  "statements": [],
+  "comments": null
+}
+`, ""},
+		{[]string{"parse"}, "let = 1", exitSyntaxError, "", "<stdin>:1:5: expected next token to be IDENT, got = instead\n"},
+		{[]string{"parse", script, script}, "", exitUsage, "", "monkey parse: too many files\n"},
+		{[]string{"parse", filepath.Join(dir, "missing.mk")}, "", exitUsage, "",
+			"monkey parse: open " + filepath.Join(dir, "missing.mk") + ": no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(tt.arguments, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: exit code wrong. expected=%d, got=%d (stderr=%q)", tt.arguments, tt.expectedCode, code, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: stdout wrong. expected=%q, got=%q", tt.arguments, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%v: stderr wrong. expected=%q, got=%q", tt.arguments, tt.expectedStderr, stderr.String())
+		}
+	}
+
+	// The JSON of a script reads back as the program that was parsed.
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"parse", "-json", script}, strings.NewReader(""), &stdout, &stderr); code != exitOK {
+		t.Fatalf("monkey parse -json failed: %s", stderr.String())
+	}
+	program := &ast.Program{}
+	if err := json.Unmarshal(stdout.Bytes(), program); err != nil {
+		t.Fatalf("json.Unmarshal failed: %s", err)
+	}
+	if program.String() != "let x = (1 + (2 * 3));" || len(program.Comments) != 1 || program.Comments[0].Text() != "// answer" {
+		t.Fatalf("program wrong. got=%q, comments=%v", program.String(), program.Comments)
+	}
+	if pos := program.Statements[0].Pos(); pos.String() != script+":2:1" {
+		t.Fatalf("position wrong. got=%s", pos)
+	}
+}
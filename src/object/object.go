@@ -3,21 +3,37 @@ package object
 import (
 	"ast"
 	"bytes"
+	"code"
 	"fmt"
+	"hash/fnv"
+	"lexer"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
 	"strings"
+	"token"
 )
 
 type ObjectType string
 
 const (
-	INTEGER_OBJ      ObjectType = "INTEGER"
-	BOOLEAN_OBJ      ObjectType = "BOOLEAN"
-	NULL_OBJ         ObjectType = "NULL"
-	RETURN_VALUE_OBJ ObjectType = "RETURN_VALUE"
-	ERROR_OBJ        ObjectType = "ERROR"
-	FUNCTION_OBJ     ObjectType = "FUNCTION"
-	STRING_OBJ       ObjectType = "STRING"
-	BUILDIN_OBJ      ObjectType = "BUILDIN"
+	INTEGER_OBJ           ObjectType = "INTEGER"
+	FLOAT_OBJ             ObjectType = "FLOAT"
+	BOOLEAN_OBJ           ObjectType = "BOOLEAN"
+	NULL_OBJ              ObjectType = "NULL"
+	RETURN_VALUE_OBJ      ObjectType = "RETURN_VALUE"
+	ERROR_OBJ             ObjectType = "ERROR"
+	FUNCTION_OBJ          ObjectType = "FUNCTION"
+	STRING_OBJ            ObjectType = "STRING"
+	BUILDIN_OBJ           ObjectType = "BUILDIN"
+	ARRAY_OBJ             ObjectType = "ARRAY"
+	HASH_OBJ              ObjectType = "HASH"
+	COMPILED_FUNCTION_OBJ ObjectType = "COMPILED_FUNCTION"
+	RANGE_OBJ             ObjectType = "RANGE"
+	ITERATOR_OBJ          ObjectType = "ITERATOR"
+	BREAK_OBJ             ObjectType = "BREAK"
+	CONTINUE_OBJ          ObjectType = "CONTINUE"
 )
 
 type Object interface {
@@ -35,6 +51,100 @@ func (i *Integer) Type() ObjectType {
 func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// BigInteger is an integer outside the int64 range. It has the same type as
+// Integer: integer arithmetic promotes to BigInteger on overflow and demotes
+// results that fit back to Integer, see NewInteger, so every integer value
+// has exactly one representation.
+type BigInteger struct {
+	Value *big.Int
+}
+
+// bigIntegerKey keeps the hash keys of big integers apart from those of
+// integers, whose Value is the number itself.
+const bigIntegerKey ObjectType = "BIG_INTEGER"
+
+func (bi *BigInteger) Type() ObjectType {
+	return INTEGER_OBJ
+}
+func (bi *BigInteger) Inspect() string {
+	return bi.Value.String()
+}
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(bi.Value.String()))
+	return HashKey{Type: bigIntegerKey, Value: h.Sum64()}
+}
+
+// NewInteger returns value as an Integer if it fits into int64 and as a
+// BigInteger otherwise.
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+	return &BigInteger{Value: value}
+}
+
+// BigValue returns the value of an Integer or a BigInteger as a *big.Int,
+// which the caller must not modify.
+func BigValue(integer Object) (*big.Int, bool) {
+	switch integer := integer.(type) {
+	case *Integer:
+		return big.NewInt(integer.Value), true
+	case *BigInteger:
+		return integer.Value, true
+	default:
+		return nil, false
+	}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect prints the shortest representation that reads back as the same
+// float. It always has a '.' or an exponent, so 2.0 does not look like the
+// integer 2, and only very large or small values use an exponent.
+func (f *Float) Inspect() string {
+	switch {
+	case math.IsNaN(f.Value):
+		return "NaN"
+	case math.IsInf(f.Value, 1):
+		return "Inf"
+	case math.IsInf(f.Value, -1):
+		return "-Inf"
+	}
+
+	abs := math.Abs(f.Value)
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.FormatFloat(f.Value, 'g', -1, 64)
+	}
+	s := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// HashKey of an integral float is the key of the equal integer, so 1.0 and 1
+// are the same key in a hash, as 1.0 == 1.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+			return (&Integer{Value: int64(f.Value)}).HashKey()
+		}
+		integer, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInteger{Value: integer}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
 
 type Boolean struct {
 	Value bool
@@ -46,6 +156,13 @@ func (b *Boolean) Type() ObjectType {
 func (b *Boolean) Inspect() string {
 	return fmt.Sprintf("%t", b.Value)
 }
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
 
 type String struct {
 	Value string
@@ -55,7 +172,12 @@ func (s *String) Type() ObjectType {
 	return STRING_OBJ
 }
 func (s *String) Inspect() string {
-	return fmt.Sprintf(`"%s"`, s.Value)
+	return lexer.Quote(s.Value)
+}
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
 type Null struct {
@@ -80,8 +202,55 @@ func (rValue *ReturnValue) Inspect() string {
 	return fmt.Sprintf("return %s", rValue.Inspect())
 }
 
+// ErrorKind classifies runtime errors, like the exception classes of other
+// interpreters.
+type ErrorKind string
+
+const (
+	TypeError         ErrorKind = "TypeError"
+	NameError         ErrorKind = "NameError"
+	ArityError        ErrorKind = "ArityError"
+	IndexError        ErrorKind = "IndexError"
+	ValueError        ErrorKind = "ValueError"
+	ZeroDivisionError ErrorKind = "ZeroDivisionError"
+	RuntimeError      ErrorKind = "RuntimeError"
+	UserError         ErrorKind = "Error" // raised by throw with a value that names no kind
+)
+
+// StackFrame is a Monkey function call that was active when an error was
+// raised. Pos is the call site in the calling function.
+type StackFrame struct {
+	Function string
+	Pos      token.Position
+}
+
+// Break and Continue are the results of break and continue statements on
+// their way out to the enclosing loop, like ReturnValue for return.
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
 type Error struct {
+	Kind    ErrorKind
 	Message string
+	Pos     token.Position // where the error was raised; zero if unknown
+	End     token.Position // end of the node that raised the error
+	Stack   []StackFrame   // innermost call first; empty if raised at the top level
+	Value   Object         // the value passed to throw; nil for errors raised by the runtime
 }
 
 func (error *Error) Type() ObjectType {
@@ -91,6 +260,64 @@ func (error *Error) Inspect() string {
 	return "ERROR: " + error.Message
 }
 
+// Traceback renders the error with its kind and, if it was raised inside
+// Monkey functions, the calls that led there, innermost first:
+//
+//	main.mk:2:12: TypeError: Type mismatch: INTEGER + BOOLEAN
+//	    at add (main.mk:2:12)
+//	    at <main> (main.mk:4:1)
+//
+// A line repeated more than maxRepeatedFrames times in a row, as by deep
+// recursion, is shown that many times followed by a count of the others.
+func (error *Error) Traceback() string {
+	var out bytes.Buffer
+
+	if error.Pos.IsValid() {
+		out.WriteString(error.Pos.String() + ": ")
+	}
+	kind := error.Kind
+	if kind == "" {
+		kind = RuntimeError
+	}
+	out.WriteString(string(kind) + ": " + error.Message + "\n")
+	if len(error.Stack) == 0 {
+		return out.String()
+	}
+
+	pos := error.Pos
+	previous, repeated := "", 0
+	for _, frame := range error.Stack {
+		line := fmt.Sprintf("    at %s (%s)\n", frame.Function, pos)
+		pos = frame.Pos
+		if line == previous {
+			repeated++
+			if repeated >= maxRepeatedFrames {
+				continue
+			}
+		} else {
+			writeRepeated(&out, repeated)
+			previous, repeated = line, 0
+		}
+		out.WriteString(line)
+	}
+	writeRepeated(&out, repeated)
+	fmt.Fprintf(&out, "    at <main> (%s)\n", pos)
+
+	return out.String()
+}
+
+// maxRepeatedFrames is the number of times Traceback shows the same line in a
+// row.
+const maxRepeatedFrames = 3
+
+// writeRepeated reports the lines of a traceback left out after a line was
+// shown again repeated times.
+func writeRepeated(out *bytes.Buffer, repeated int) {
+	if omitted := repeated - (maxRepeatedFrames - 1); omitted > 0 {
+		fmt.Fprintf(out, "    [previous line repeated %d more times]\n", omitted)
+	}
+}
+
 func NewEnvironment() *Environment {
 	return &Environment{store: make(map[string]Object)}
 }
@@ -102,26 +329,105 @@ func NewEnclosingEnvironment(outer *Environment) *Environment {
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	decls    map[string]ast.Node // declaration of each name in store bound by let, const or a parameter
+	consts   map[string]ast.Node // declaration of each constant in store
+	reserved map[string]bool     // names bound in this environment later on, see Reserve
+	outer    *Environment
 }
 
 func (env *Environment) Get(name string) (Object, bool) {
 	object, ok := env.store[name]
-	if !ok && env.outer != nil {
+	if !ok && env.outer != nil && !env.reserved[name] {
 		object, ok = env.outer.Get(name)
 	}
 	return object, ok
 }
+
+// Reserve makes name belong to this environment before it is bound: until
+// then Get does not find it and Assign fails, rather than using a binding of
+// an outer environment. A function call reserves its local names up front, so
+// a name means the same binding throughout the function body.
+func (env *Environment) Reserve(name string) {
+	if env.reserved == nil {
+		env.reserved = make(map[string]bool)
+	}
+	env.reserved[name] = true
+}
 func (env *Environment) Set(name string, object Object) Object {
 	env.store[name] = object
 	return object
 }
 
+// Declare binds name like Set and records decl, the let statement or the
+// parameter declaring it, see Declaration.
+func (env *Environment) Declare(name string, object Object, decl ast.Node) Object {
+	if env.decls == nil {
+		env.decls = make(map[string]ast.Node)
+	}
+	env.decls[name] = decl
+	return env.Set(name, object)
+}
+
+// SetConst binds name like Declare and marks the binding constant.
+func (env *Environment) SetConst(name string, object Object, decl ast.Node) Object {
+	if env.consts == nil {
+		env.consts = make(map[string]ast.Node)
+	}
+	env.consts[name] = decl
+	return env.Declare(name, object, decl)
+}
+
+// Declaration returns the let statement or the parameter that declared name in
+// this environment, not looking at outer ones. Running that same statement
+// again, as the body of a loop does, is not a redeclaration.
+func (env *Environment) Declaration(name string) (ast.Node, bool) {
+	decl, ok := env.decls[name]
+	return decl, ok
+}
+
+// ConstDeclaration is like Declaration for the names declared as constants.
+func (env *Environment) ConstDeclaration(name string) (ast.Node, bool) {
+	decl, ok := env.consts[name]
+	return decl, ok
+}
+
+// IsConst reports whether name resolves to a constant binding.
+func (env *Environment) IsConst(name string) bool {
+	for e := env; e != nil; e = e.outer {
+		if _, ok := e.store[name]; ok {
+			_, isConst := e.consts[name]
+			return isConst
+		}
+		if e.reserved[name] {
+			return false
+		}
+	}
+	return false
+}
+
+// Assign rebinds name in the innermost environment that defines it. It
+// reports false, and changes nothing, if no environment defines name.
+// Callers check IsConst first.
+func (env *Environment) Assign(name string, object Object) bool {
+	for e := env; e != nil; e = e.outer {
+		if _, ok := e.store[name]; ok {
+			e.store[name] = object
+			return true
+		}
+		if e.reserved[name] {
+			return false
+		}
+	}
+	return false
+}
+
 type Function struct {
+	Name        string // name of the let binding the literal was defined by, if any
 	Parameters  []*ast.Identifier
 	Body        *ast.BlockStatement
 	Environment *Environment
+	Literal     *ast.FunctionLiteral // for the default values and the rest parameter
 }
 
 func (function *Function) Type() ObjectType {
@@ -131,8 +437,12 @@ func (function *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, param := range function.Parameters {
-		params = append(params, param.String())
+	if function.Literal != nil {
+		params = function.Literal.ParameterStrings()
+	} else {
+		for _, param := range function.Parameters {
+			params = append(params, param.String())
+		}
 	}
 
 	out.WriteString("fn (")
@@ -155,3 +465,275 @@ func (buildin *Buildin) Type() ObjectType {
 func (buildin *Buildin) Inspect() string {
 	return "buildin function"
 }
+
+type Array struct {
+	Elements []Object
+}
+
+func (array *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+func (array *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, element := range array.Elements {
+		elements = append(elements, element.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashKey identifies a Hashable object inside a Hash. Two objects with equal
+// values produce equal keys even if they are distinct instances. Keys of
+// strings and big integers are hashes of their value, so two different values
+// may share a key too.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps keys to values. The pairs are stored by the HashKey of their key
+// and compared by value on lookup, so keys sharing a HashKey stay apart.
+type Hash struct {
+	pairs map[HashKey][]HashPair
+	size  int
+}
+
+func NewHash() *Hash {
+	return &Hash{pairs: make(map[HashKey][]HashPair)}
+}
+
+// Get returns the pair whose key equals key.
+func (hash *Hash) Get(key Hashable) (HashPair, bool) {
+	for _, pair := range hash.pairs[key.HashKey()] {
+		if sameKey(pair.Key, key) {
+			return pair, true
+		}
+	}
+	return HashPair{}, false
+}
+
+// Set binds key to value. If an equal key is present it keeps its pair and
+// only the value is replaced, so {1: "a", 1.0: "b"} is {1: "b"}.
+func (hash *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	bucket := hash.pairs[hashKey]
+	for i, pair := range bucket {
+		if sameKey(pair.Key, key) {
+			bucket[i].Value = value
+			return
+		}
+	}
+	hash.pairs[hashKey] = append(bucket, HashPair{Key: key, Value: value})
+	hash.size++
+}
+
+// Delete removes the pair whose key equals key, if any.
+func (hash *Hash) Delete(key Hashable) {
+	hashKey := key.HashKey()
+	bucket := hash.pairs[hashKey]
+	for i, pair := range bucket {
+		if sameKey(pair.Key, key) {
+			bucket = append(bucket[:i:i], bucket[i+1:]...)
+			if len(bucket) == 0 {
+				delete(hash.pairs, hashKey)
+			} else {
+				hash.pairs[hashKey] = bucket
+			}
+			hash.size--
+			return
+		}
+	}
+}
+
+// Len returns the number of pairs.
+func (hash *Hash) Len() int {
+	return hash.size
+}
+
+// sameKey reports whether two keys with the same HashKey are equal. Integer,
+// boolean and non-integral float keys hold the value itself and cannot
+// collide; strings and big integers, and the floats equal to them, are hashed.
+func sameKey(left, right Object) bool {
+	if left, ok := left.(*String); ok {
+		right, ok := right.(*String)
+		return ok && left.Value == right.Value
+	}
+	leftValue, leftOk := keyInteger(left)
+	rightValue, rightOk := keyInteger(right)
+	if leftOk && rightOk {
+		return leftValue.Cmp(rightValue) == 0
+	}
+	return true
+}
+
+// keyInteger returns the value of a big integer key or of a float key equal
+// to one.
+func keyInteger(key Object) (*big.Int, bool) {
+	switch key := key.(type) {
+	case *BigInteger:
+		return key.Value, true
+	case *Float:
+		if key.HashKey().Type != bigIntegerKey {
+			return nil, false
+		}
+		integer, _ := big.NewFloat(key.Value).Int(nil)
+		return integer, true
+	default:
+		return nil, false
+	}
+}
+
+func (hash *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+func (hash *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hash.SortedPairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// SortedPairs returns the pairs in a deterministic order: grouped by key type,
+// numbers ascending, other keys by their Inspect() text.
+func (hash *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, hash.size)
+	for _, bucket := range hash.pairs {
+		pairs = append(pairs, bucket...)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		left, right := pairs[i].Key, pairs[j].Key
+		if left.Type() != right.Type() {
+			return left.Type() < right.Type()
+		}
+		if left.Type() == INTEGER_OBJ {
+			leftInteger, leftOk := left.(*Integer)
+			rightInteger, rightOk := right.(*Integer)
+			if leftOk && rightOk {
+				return leftInteger.Value < rightInteger.Value
+			}
+			leftValue, _ := BigValue(left)
+			rightValue, _ := BigValue(right)
+			return leftValue.Cmp(rightValue) < 0
+		}
+		if leftFloat, ok := left.(*Float); ok {
+			return leftFloat.Value < right.(*Float).Value
+		}
+		return left.Inspect() < right.Inspect()
+	})
+	return pairs
+}
+
+// Range is the sequence of integers from Start up to but not including Stop,
+// in increments of Step.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType {
+	return RANGE_OBJ
+}
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Len returns the number of integers in the range. It computes with unsigned
+// integers, which hold the distance between any two int64 values.
+func (r *Range) Len() uint64 {
+	var distance, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		distance, step = uint64(r.Stop)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.Stop:
+		distance, step = uint64(r.Start)-uint64(r.Stop), -uint64(r.Step)
+	default:
+		return 0
+	}
+	return (distance-1)/step + 1
+}
+
+// Iterator walks the elements of an iterable for a for-in loop. Next returns
+// false once the elements are exhausted.
+type Iterator struct {
+	Next func() (Object, bool)
+}
+
+func (it *Iterator) Type() ObjectType {
+	return ITERATOR_OBJ
+}
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+// CompiledFunction is the bytecode produced by the compiler for a function literal.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	MinArguments  int                // parameters without a default value
+	Variadic      bool               // the last parameter collects the remaining arguments
+	LocalNames    []string           // names of the local slots, for error messages
+	Positions     map[int]token.Span // instruction offset -> source span
+	Literal       *ast.FunctionLiteral
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Locals holds the local slots of one call of a compiled function. Closures
+// keep a reference to the Locals of the call that created them, so captured
+// variables are shared rather than copied.
+type Locals struct {
+	Values []Object
+	Names  []string
+	Outer  *Locals
+}
+
+type Closure struct {
+	Fn  *CompiledFunction
+	Env *Locals // nil for functions defined at the top level
+}
+
+// Type is FUNCTION_OBJ so that programs cannot tell which engine runs them.
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+func (c *Closure) Inspect() string {
+	if c.Fn.Literal == nil {
+		return fmt.Sprintf("Closure[%p]", c)
+	}
+	function := &Function{Parameters: c.Fn.Literal.Parameters, Body: c.Fn.Literal.Body, Literal: c.Fn.Literal}
+	return function.Inspect()
+}
@@ -0,0 +1,173 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"token"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyType(t *testing.T) {
+	one := &Integer{Value: 1}
+	truth := &Boolean{Value: true}
+
+	if one.HashKey() == truth.HashKey() {
+		t.Errorf("1 and true must not share a hash key")
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	a, b := &String{Value: "a"}, &String{Value: "b"}
+
+	// store b under the key of a as if their hashes collided
+	hash := NewHash()
+	hash.pairs[a.HashKey()] = []HashPair{{Key: b, Value: &Integer{Value: 2}}}
+	hash.size = 1
+
+	if _, ok := hash.Get(a); ok {
+		t.Fatalf("a found in a hash holding only b")
+	}
+	hash.Set(a, &Integer{Value: 1})
+	if hash.Len() != 2 {
+		t.Fatalf("Set overwrote a colliding key. got %d pairs", hash.Len())
+	}
+	if pair, ok := hash.Get(a); !ok || pair.Value.Inspect() != "1" {
+		t.Errorf("wrong pair for a. got=%v", pair)
+	}
+	hash.Delete(a)
+	if hash.Len() != 1 || hash.pairs[a.HashKey()][0].Key != b {
+		t.Errorf("Delete removed a colliding key. got=%v", hash.pairs)
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	if _, ok := NewInteger(big.NewInt(math.MaxInt64)).(*Integer); !ok {
+		t.Errorf("NewInteger of MaxInt64 is not an Integer")
+	}
+
+	large := new(big.Int).Add(big.NewInt(math.MaxInt64), big.NewInt(1))
+	bigInteger, ok := NewInteger(large).(*BigInteger)
+	if !ok {
+		t.Fatalf("NewInteger of MaxInt64 + 1 is not a BigInteger")
+	}
+	if bigInteger.Type() != INTEGER_OBJ {
+		t.Errorf("BigInteger type wrong. got=%s", bigInteger.Type())
+	}
+	if bigInteger.HashKey() != NewInteger(new(big.Int).Set(large)).(*BigInteger).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+	if bigInteger.HashKey() == (&Integer{Value: math.MinInt64}).HashKey() {
+		t.Errorf("MaxInt64 + 1 and MinInt64 must not share a hash key")
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []Hashable{&String{Value: "b"}, &Integer{Value: 10}, &Boolean{Value: true}, &Integer{Value: 2}, &String{Value: "a"}} {
+		hash.Set(key, &Integer{Value: 0})
+	}
+
+	expected := `{true: 0, 2: 0, 10: 0, "a": 0, "b": 0}`
+	for i := 0; i < 10; i++ {
+		if hash.Inspect() != expected {
+			t.Fatalf("hash.Inspect() wrong. expected=%s, got=%s", expected, hash.Inspect())
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{0, "0.0"},
+		{1e-9, "1e-09"},
+		{0.000001, "0.000001"},
+		{123456789, "123456789.0"},
+		{1e21, "1e+21"},
+		{0.30000000000000004, "0.30000000000000004"},
+		{math.Inf(1), "Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := (&Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("Inspect of %v wrong. expected=%s, got=%s", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	err := &Error{
+		Kind:    TypeError,
+		Message: "Type mismatch: INTEGER + BOOLEAN",
+		Pos:     token.Position{Filename: "a.mk", Line: 2, Column: 3},
+		Stack: []StackFrame{
+			{Function: "inner", Pos: token.Position{Filename: "a.mk", Line: 5, Column: 1}},
+			{Function: "outer", Pos: token.Position{Filename: "a.mk", Line: 7, Column: 2}},
+		},
+	}
+	expected := `a.mk:2:3: TypeError: Type mismatch: INTEGER + BOOLEAN
+    at inner (a.mk:2:3)
+    at outer (a.mk:5:1)
+    at <main> (a.mk:7:2)
+`
+	if got := err.Traceback(); got != expected {
+		t.Errorf("traceback wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	// a line repeated in a row is shown three times
+	recursion := &Error{Kind: RuntimeError, Message: "stack overflow", Pos: token.Position{Line: 1, Column: 16}}
+	for i := 0; i < 5; i++ {
+		recursion.Stack = append(recursion.Stack, StackFrame{Function: "f", Pos: token.Position{Line: 1, Column: 16}})
+	}
+	recursion.Stack = append(recursion.Stack, StackFrame{Function: "f", Pos: token.Position{Line: 2, Column: 1}})
+	expected = `1:16: RuntimeError: stack overflow
+    at f (1:16)
+    at f (1:16)
+    at f (1:16)
+    [previous line repeated 3 more times]
+    at <main> (2:1)
+`
+	if got := recursion.Traceback(); got != expected {
+		t.Errorf("traceback wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+	recursion.Stack = recursion.Stack[:3]
+	expected = `1:16: RuntimeError: stack overflow
+    at f (1:16)
+    at f (1:16)
+    at f (1:16)
+    at <main> (1:16)
+`
+	if got := recursion.Traceback(); got != expected {
+		t.Errorf("traceback wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	err = &Error{Message: "stack overflow"}
+	if got := err.Traceback(); got != "RuntimeError: stack overflow\n" {
+		t.Errorf("traceback wrong. got=%q", got)
+	}
+}
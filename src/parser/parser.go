@@ -5,31 +5,45 @@ import "lexer"
 import (
 	"token"
 	"fmt"
-	"log"
+	"math/big"
 	"strconv"
 )
 
 const (
 	_           int = iota
 	LOWEST
+	ASSIGN       // = or +=
+	LOGICAL_OR   // ||
+	LOGICAL_AND  // &&
 	EQUALS       // ==
 	LESSGREATER  // > or <
 	SUM          // +
 	PRODUCT      // *
 	PREFIX       // -X or !X
 	CALL         // myFunction(X)
+	INDEX        // array[index]
 )
 
 var precendence = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NE:       EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.EQ:              EQUALS,
+	token.NE:              EQUALS,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LE:              LESSGREATER,
+	token.GE:              LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 type (
@@ -43,6 +57,15 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
+	loopDepth int // number of loops around the current statement within its function
+
+	// panicking is set by a syntax error and cleared once the parser has
+	// skipped to the next statement. Errors in between are not reported,
+	// they are most likely caused by the first one.
+	panicking bool
+
+	comments []*ast.Comment // skipped COMMENT tokens, if the lexer emits them
+
 	prefixParseFn map[token.TokenType]prefixParseFn
 	infixParseFn  map[token.TokenType]infixParseFn
 }
@@ -53,7 +76,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFn = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBooleanExpression)
@@ -61,9 +86,13 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 
 	p.infixParseFn = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
@@ -72,6 +101,15 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NE, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LE, p.parseInfixExpression)
+	p.registerInfix(token.GE, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -82,6 +120,10 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekToken = p.l.NextToken()
+	}
 }
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFn[tokenType] = fn
@@ -98,9 +140,28 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-	if err != nil {
+	if err == nil {
+		lit.Value = value
+		return lit
+	}
+	bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0)
+	if !ok {
 		msg := fmt.Sprintf("count not parse %q as Integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Pos, msg)
+		return nil
+	}
+
+	lit.Big = bigValue
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as Float", p.curToken.Literal)
+		p.addError(p.curToken.Pos, msg)
 		return nil
 	}
 
@@ -112,6 +173,36 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseInterpolatedString parses the parts of a string from its
+// TEMPLATE_HEAD to its TEMPLATE_TAIL.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = append(str.Parts, p.parseStringLiteral())
+
+	for !p.curTokenIs(token.TEMPLATE_TAIL) {
+		if p.peekTokenIs(token.TEMPLATE_MIDDLE) || p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.syntaxError(p.peekToken.Pos, "empty interpolation in string")
+			return nil
+		}
+		p.nextToken()
+		expression := p.parseExpression(LOWEST)
+		if expression == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, expression)
+
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			msg := fmt.Sprintf("expected } to end the interpolation, got %s instead", p.peekToken.Type)
+			p.syntaxError(p.peekToken.Pos, msg)
+			return nil
+		}
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseStringLiteral())
+	}
+
+	return str
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -136,38 +227,170 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(p.curToken.Pos, fmt.Sprintf("cannot assign to %s", target))
+	}
+
+	// assignment is right associative: a = b = c is a = (b = c)
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
 func (p *Parser) parseCallExpression(left ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{
 		Token:     p.curToken,
 		Function:  left,
 		Arguments: p.parseCallArguments(),
 	}
+	if p.curTokenIs(token.RPAREN) {
+		expression.Rparen = p.curToken
+	}
 
 	return expression
 }
 
+// parseCallArguments parses the arguments of a call, any of which may be
+// spread, f(...args).
 func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+	list := []ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return args
+		return list
+	}
+
+	for {
+		p.nextToken()
+		list = append(list, p.parseCallArgument())
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseCallArgument() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+	spread := &ast.SpreadExpression{Token: p.curToken}
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
+// parseExpressionList parses comma separated expressions up to and including end.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	array.Rbracket = p.curToken
+
+	return array
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
+// parseIndexExpression parses `left[index]` as well as the slice forms
+// `left[low:high]`, `left[low:]`, `left[:high]` and `left[:]`.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	lbracket := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if !p.peekTokenIs(token.COLON) {
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return &ast.IndexExpression{Token: lbracket, Left: left, Index: index, Rbracket: p.curToken}
+	}
+
+	slice := &ast.SliceExpression{Token: lbracket, Left: left, Low: index}
+	p.nextToken()
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.High = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	slice.Rbracket = p.curToken
+
+	return slice
 }
 
 func (p *Parser) parseBooleanExpression() ast.Expression {
@@ -220,6 +443,51 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		exp.Parameter = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		exp.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		exp.Finally = p.parseBlockStatement()
+	}
+
+	if exp.Catch == nil && exp.Finally == nil {
+		p.syntaxError(p.peekToken.Pos, fmt.Sprintf("expected catch or finally after try block, got %s instead", p.peekToken.Type))
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	fl := &ast.FunctionLiteral{Token: p.curToken}
 
@@ -227,13 +495,19 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	fl.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(fl) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	// break and continue cannot reach a loop outside the function
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	fl.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return fl
 }
@@ -247,23 +521,83 @@ func (p *Parser) ParseProgram() *ast.Program {
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
+		if p.panicking {
+			p.synchronize(false)
+		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 	return program
 }
 
+// statementStarts are the tokens that begin a statement other than an
+// expression statement.
+var statementStarts = map[token.TokenType]bool{
+	token.LET:      true,
+	token.CONST:    true,
+	token.RETURN:   true,
+	token.THROW:    true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
+// synchronize recovers from a syntax error by skipping the rest of the
+// statement: up to its semicolon, or to the token before the next statement,
+// the '}' closing the enclosing block if inBlock, or the end of input. Braces
+// opened in the skipped part are skipped along with their contents. If the
+// error is at the '}' closing the enclosing block, synchronize stops on it
+// and reports true.
+func (p *Parser) synchronize(inBlock bool) (closed bool) {
+	p.panicking = false
+
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 && inBlock {
+				return true
+			}
+			if depth > 0 {
+				depth--
+			}
+		}
+		if depth == 0 {
+			if p.curTokenIs(token.SEMICOLON) {
+				return
+			}
+			if statementStarts[p.peekToken.Type] || inBlock && p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+				return false
+			}
+		}
+		p.nextToken()
+	}
+	return false
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
-		return p.parserReturnStatement()
+		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -278,24 +612,116 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
-
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	if literal, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		literal.Name = stmt.Name.Value
 	}
 
+	p.skipSemicolon()
+
 	return stmt
 }
 
-func (p *Parser) parserReturnStatement() *ast.ReturnStatement {
+func (p *Parser) parseReturnStatement() ast.Statement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	p.skipSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	p.skipSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
 	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+
+	p.skipSemicolon()
+
+	return body
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+
+	if p.loopDepth == 0 {
+		p.addError(p.curToken.Pos, fmt.Sprintf("%s outside of a loop", p.curToken.Literal))
+	}
+
+	p.skipSemicolon()
 
 	return stmt
 }
@@ -322,10 +748,34 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// addError records msg prefixed with the source position it refers to.
+func (p *Parser) addError(pos token.Position, msg string) {
+	p.errors = append(p.errors, fmt.Sprintf("%s: %s", pos, msg))
+}
+
+// syntaxError records an error after which the parser cannot make sense of
+// the rest of the statement. It is not recorded if the parser is already
+// skipping the statement because of an earlier one.
+func (p *Parser) syntaxError(pos token.Position, msg string) {
+	if p.panicking {
+		return
+	}
+	p.addError(pos, msg)
+	p.panicking = true
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.syntaxError(p.peekToken.Pos, msg)
+}
+
+// skipSemicolon consumes the optional ';' ending a statement. After a syntax
+// error it is left to synchronize, as the error may be at a '}' before it.
+func (p *Parser) skipSemicolon() {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
@@ -333,16 +783,19 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
-	}
+	p.skipSemicolon()
 
 	return stmt
 }
 
 func (p *Parser) noPrefixParseFnError(tokenType token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", tokenType)
-	p.errors = append(p.errors, msg)
+	if tokenType == token.ILLEGAL {
+		// the lexer explains what is wrong in the literal
+		p.syntaxError(p.curToken.Pos, p.curToken.Literal)
+		return
+	}
+	msg := fmt.Sprintf("expected an expression, got %s instead", tokenType)
+	p.syntaxError(p.curToken.Pos, msg)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -364,6 +817,15 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
+// Precedence returns how tightly the operator of type t binds its operands,
+// LOWEST if t is not an infix, assignment, call or index operator.
+func Precedence(t token.TokenType) int {
+	if p, ok := precendence[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precendence[p.peekToken.Type]; ok {
 		return p
@@ -388,35 +850,69 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.panicking && p.synchronize(true) {
+			break
+		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken
+	} else {
+		msg := fmt.Sprintf("expected } to close the block opened on line %d, got EOF instead", block.Token.Pos.Line)
+		p.syntaxError(p.curToken.Pos, msg)
+	}
 
 	return block
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses the parameters of fl up to and including the
+// closing ')'. A parameter may have a default value, fn(a, b = 2), which the
+// parameters after it need as well, and the last one may be a rest
+// parameter, fn(first, ...rest).
+func (p *Parser) parseFunctionParameters(fl *ast.FunctionLiteral) bool {
+	fl.Parameters = []*ast.Identifier{}
 
-	log.Printf("parseFunctionParameters: %s", identifiers)
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	for {
+		if fl.Variadic {
+			p.syntaxError(p.peekToken.Pos, "rest parameter must be the last parameter")
+			return false
+		}
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			fl.Variadic = true
+		}
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		fl.Parameters = append(fl.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			if fl.Variadic {
+				p.syntaxError(p.peekToken.Pos, "rest parameter cannot have a default value")
+				return false
+			}
+			p.nextToken()
+			p.nextToken()
+			for len(fl.Defaults) < len(fl.Parameters)-1 {
+				fl.Defaults = append(fl.Defaults, nil)
+			}
+			fl.Defaults = append(fl.Defaults, p.parseExpression(LOWEST))
+		} else if len(fl.Defaults) > 0 && !fl.Variadic {
+			p.syntaxError(ident.Pos(), fmt.Sprintf("parameter %s without default value follows one with a default value", ident.Value))
+			return false
+		}
 
-	log.Printf("parseFunctionParameters: %s", identifiers)
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
-	return identifiers
+	return p.expectPeek(token.RPAREN)
 }
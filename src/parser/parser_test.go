@@ -7,6 +7,7 @@ import (
 	"token"
 	"fmt"
 	"log"
+	"strings"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -43,6 +44,28 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	program := parseProgramWithParserErrors(t, "const x = fn() { 1 };")
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if !stmt.IsConst() || stmt.Name.Value != "x" {
+		t.Errorf("stmt is not const x. got=%s", stmt.String())
+	}
+	if literal := stmt.Value.(*ast.FunctionLiteral); literal.Name != "x" {
+		t.Errorf("function literal not named by const. got=%q", literal.Name)
+	}
+	if stmt.String() != "const x = fn() {1;};" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got %q", s.TokenLiteral())
@@ -101,6 +124,18 @@ func TestReturnStatement(t *testing.T) {
 	}
 }
 
+func TestReturnStatementWithoutSemicolon(t *testing.T) {
+	program := parseProgramWithParserErrors(t, "fn(x) { return x }\nreturn 1")
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+	if _, ok := program.Statements[1].(*ast.ReturnStatement); !ok {
+		t.Errorf("stmt not *ast.ReturnStatement. got=%T", program.Statements[1])
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := `foobar;`
 
@@ -287,6 +322,29 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testStatement(t, program, tests)
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []IOPair{
+		{"3.14", "3.14"},
+		{"-1e-9", "(-1e-9)"},
+		{"1.5 * 2", "(1.5 * 2)"},
+	}
+	testParsingUsingString(tests, t)
+
+	program := parseProgramWithParserErrors(t, "2.5E3;")
+	literal, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FloatLiteral)
+	if !ok || literal.Value != 2500 {
+		t.Errorf("expression is not the float 2500. got=%#v", program.Statements[0])
+	}
+}
+
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	program := parseProgramWithParserErrors(t, "18446744073709551616;")
+	literal, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	if !ok || literal.Big == nil || literal.Big.String() != "18446744073709551616" {
+		t.Errorf("expression is not the integer 18446744073709551616. got=%#v", program.Statements[0])
+	}
+}
+
 func parseProgramWithParserErrors(t *testing.T, input string) *ast.Program {
 	p := New(lexer.New(input))
 	program := p.ParseProgram()
@@ -322,6 +380,8 @@ func TestParsingInfixExpression(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
 	}
 
 	for _, tt := range infixTests {
@@ -420,6 +480,22 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"fn(x, y){ x + y; }",
 			"fn(x,y) {(x + y);}",
 		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c || d",
+			"((a || (b && c)) || d)",
+		},
+		{
+			"a == b && c < d || !e",
+			"(((a == b) && (c < d)) || (!e))",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
 	}
 
 	testParsingUsingString(tests, t)
@@ -592,6 +668,8 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"true && false", true, "&&", false},
+		{"false || true", false, "||", true},
 	}
 
 	for _, tt := range infixTests {
@@ -672,6 +750,46 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStatement.Expression, "x", "+", "y")
 }
 
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []IOPair{
+		{"fn(a, b = 2) { a }", "fn(a,b = 2) {a;}"},
+		{"fn(a = 1 + 2, ...rest) { a }", "fn(a = (1 + 2),...rest) {a;}"},
+		{"fn(...rest) { rest }", "fn(...rest) {rest;}"},
+		{"f(1, ...xs, ...[2])", "f(1, ...xs, ...[2])"},
+	}
+	testParsingUsingString(tests, t)
+
+	program := parseProgramWithParserErrors(t, "fn(a, b = 2, ...rest) {}")
+	literal := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if least, most := literal.Arity(); least != 1 || most != -1 {
+		t.Errorf("literal.Arity() wrong. expected=1, -1, got=%d, %d", least, most)
+	}
+	if literal.Default(0) != nil || literal.Default(1).String() != "2" || literal.Default(2) != nil {
+		t.Errorf("literal.Defaults wrong. got=%v", literal.Defaults)
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) {}", "1:11: parameter b without default value follows one with a default value"},
+		{"fn(...a, b) {}", "1:10: rest parameter must be the last parameter"},
+		{"fn(...a = 1) {}", "1:9: rest parameter cannot have a default value"},
+		{"fn(1) {}", "1:4: expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %s. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestStringLiteralParsing(t *testing.T) {
 	inputs := `"TEST"`
 
@@ -686,6 +804,82 @@ func TestStringLiteralParsing(t *testing.T) {
 	log.Printf("%s", expressionStatement)
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"hello ${name}, you are ${age + 1}!"`
+
+	program := parseProgramWithParserErrors(t, input)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements has wrong length. Expected=%d. Got=%d",
+			1, len(program.Statements))
+	}
+	str, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("expression is not *ast.InterpolatedString. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+
+	expected := []string{"hello ", "name", ", you are ", "(age + 1)", "!"}
+	if len(str.Parts) != len(expected) {
+		t.Fatalf("wrong number of parts. expected=%d, got=%d", len(expected), len(str.Parts))
+	}
+	for i, part := range str.Parts {
+		if _, isText := part.(*ast.StringLiteral); isText != (i%2 == 0) {
+			t.Errorf("parts[%d] is %T", i, part)
+		}
+		if part.String() != expected[i] {
+			t.Errorf("parts[%d] wrong. expected=%q, got=%q", i, expected[i], part.String())
+		}
+	}
+	if str.String() != `"hello ${name}, you are ${(age + 1)}!"` {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+	if str.End().Column != len(input)+1 {
+		t.Errorf("str.End() wrong. got=%s", str.End())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${} b"`, "1:6: empty interpolation in string"},
+		{`"a ${x y} b"`, "1:8: expected } to end the interpolation, got IDENT instead"},
+		{`"a ${x`, "1:7: expected } to end the interpolation, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "abc`, "1:9: unterminated string"},
+		{`let s = "a\qb";`, `1:9: invalid escape sequence \q`},
+		{"1 + @", "1:5: illegal character '@'"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5)`
 	program := parseProgramWithParserErrors(t, input)
@@ -736,3 +930,320 @@ func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
 	}
 	return true
 }
+
+func TestNodePosition(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y;
+};
+add(1, 2)`
+
+	program := parseProgramWithParserErrors(t, input)
+
+	tests := []struct {
+		node     ast.Node
+		pos, end string
+	}{
+		{program, "1:1", "4:10"},
+		{program.Statements[0], "1:1", "3:2"},
+		{program.Statements[0].(*ast.LetStatement).Value, "1:11", "3:2"},
+		{program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body.Statements[0], "2:3", "2:8"},
+		{program.Statements[1], "4:1", "4:10"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments[1], "4:8", "4:9"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.pos {
+			t.Errorf("tests[%d] %s: Pos() wrong. expected=%s, got=%s", i, tt.node, tt.pos, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.end {
+			t.Errorf("tests[%d] %s: End() wrong. expected=%s, got=%s", i, tt.node, tt.end, tt.node.End())
+		}
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	p := New(lexer.NewWithFilename("test.mk", "let x = 1;\nlet = 5;"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+	expected := "test.mk:2:5: expected next token to be IDENT, got = instead"
+	if errors[0] != expected {
+		t.Errorf("expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	program := parseProgramWithParserErrors(t, input)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestIndexExpressionParsing(t *testing.T) {
+	tests := []IOPair{
+		{"[]", "[]"},
+		{"myArray[1 + 1]", "(myArray[(1 + 1)])"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:-1]", "(a[:(-1)])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[1][2:]", "((a[1])[2:])"},
+	}
+
+	testParsingUsingString(tests, t)
+}
+
+func TestHashLiteralParsing(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	program := parseProgramWithParserErrors(t, input)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.Value != expected[i].key {
+			t.Errorf("key[%d] wrong. expected=%s, got=%s", i, expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestHashLiteralParsingString(t *testing.T) {
+	tests := []IOPair{
+		{"{}", "{}"},
+		{`{"one": 0 + 1, 2: 10 - 8, true: 15 / 5}`, "{one: (0 + 1), 2: (10 - 8), true: (15 / 5)}"},
+		{`h["a"]`, "(h[a])"},
+	}
+
+	testParsingUsingString(tests, t)
+}
+
+func TestTryExpressionParsing(t *testing.T) {
+	tests := []IOPair{
+		{`try { x } catch (e) { y }`, "try {x;} catch (e) {y;}"},
+		{`try { x } finally { z }`, "try {x;} finally {z;}"},
+		{`try { x } catch (e) { y } finally { z }`, "try {x;} catch (e) {y;} finally {z;}"},
+		{`throw "boom";`, "throw boom;"},
+		{`throw 1 + 2`, "throw (1 + 2);"},
+	}
+
+	testParsingUsingString(tests, t)
+
+	program := parseProgramWithParserErrors(t, `let f = fn() { 1 };`)
+	literal := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if literal.Name != "f" {
+		t.Errorf("literal.Name not %q. got=%q", "f", literal.Name)
+	}
+}
+
+func TestTryExpressionParsingErrors(t *testing.T) {
+	tests := []IOPair{
+		{"try { x }", "1:10: expected catch or finally after try block, got EOF instead"},
+		{"try { x } catch { y }", "1:17: expected next token to be (, got { instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.Input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.Output {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.Input, tt.Output, errors)
+		}
+	}
+}
+
+func TestLoopParsing(t *testing.T) {
+	tests := []IOPair{
+		{`while (x < 10) { x }`, "while ((x < 10)) {x;}"},
+		{`for (x in [1, 2]) { puts(x); }`, "for (x in [1, 2]) {puts(x);}"},
+		{`while (true) { break; continue }`, "while (true) {break;;continue;;}"},
+		{`for (x in range(3)) { if (x) { break } };`, "for (x in range(3)) {if (x) {break;;};}"},
+	}
+
+	testParsingUsingString(tests, t)
+}
+
+func TestLoopParsingErrors(t *testing.T) {
+	tests := []IOPair{
+		{"break;", "1:1: break outside of a loop"},
+		{"if (true) { continue }", "1:13: continue outside of a loop"},
+		{"while (true) { fn() { break } }", "1:23: break outside of a loop"},
+		{"for (x of y) { x }", "1:8: expected next token to be IN, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.Input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.Output {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.Input, tt.Output, errors)
+		}
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []IOPair{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 5", "(x = (y = 5))"},
+		{"x += 1 + 2 * 3", "(x += (1 + (2 * 3)))"},
+		{"a[i] -= 1", "((a[i]) -= 1)"},
+		{"h[a][b] = c == d", "(((h[a])[b]) = (c == d))"},
+		{"f(x = 1)", "f((x = 1))"},
+	}
+
+	testParsingUsingString(tests, t)
+}
+
+func TestAssignExpressionParsingErrors(t *testing.T) {
+	tests := []IOPair{
+		{"1 = 2", "1:3: cannot assign to 1"},
+		{"a + b = c", "1:7: cannot assign to (a + b)"},
+		{"f() = 1", "1:5: cannot assign to f()"},
+		{"a[1:2] = b", "1:8: cannot assign to (a[1:2])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.Input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.Output {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.Input, tt.Output, errors)
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []string
+		statements int
+	}{
+		{
+			"let = 5;\nlet y 3;\nlet z = 1 +;\nputs(z)",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"2:7: expected next token to be =, got INT instead",
+				"3:12: expected an expression, got ; instead",
+			},
+			2,
+		},
+		{
+			"let f = fn(x) {\n  let = 1;\n  return x\n};\nlet g = ;\nif (x { 1 }\nlet ok = 2",
+			[]string{
+				"2:7: expected next token to be IDENT, got = instead",
+				"5:9: expected an expression, got ; instead",
+				"6:7: expected next token to be ), got { instead",
+			},
+			4,
+		},
+		{
+			"let x = (1 + 2\nlet y = [1, 2\nlet z = {1: 2}",
+			[]string{
+				"2:1: expected next token to be ), got LET instead",
+				"3:1: expected next token to be ], got LET instead",
+			},
+			3,
+		},
+		{
+			"while (true) { let = 1 } x = 2; let y = ;",
+			[]string{
+				"1:20: expected next token to be IDENT, got = instead",
+				"1:41: expected an expression, got ; instead",
+			},
+			3,
+		},
+		{
+			"fn(a) { a",
+			[]string{"1:10: expected } to close the block opened on line 1, got EOF instead"},
+			1,
+		},
+		{
+			"let a = 1 +",
+			[]string{"1:12: expected an expression, got EOF instead"},
+			1,
+		},
+		{
+			"let f = fn() { 1 + }; let g = 2; g",
+			[]string{"1:20: expected an expression, got } instead"},
+			3,
+		},
+		{
+			"let f = fn() { let a = }; let g = 2",
+			[]string{"1:24: expected an expression, got } instead"},
+			2,
+		},
+		{
+			"if (true) { 1 + }",
+			[]string{"1:17: expected an expression, got } instead"},
+			1,
+		},
+		{
+			"if (true) { let = }\nlet x = 1",
+			[]string{"1:17: expected next token to be IDENT, got = instead"},
+			2,
+		},
+		{
+			"} let a = 1; }",
+			[]string{
+				"1:1: expected an expression, got } instead",
+				"1:14: expected an expression, got } instead",
+			},
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if strings.Join(errors, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, errors)
+		}
+		if len(program.Statements) != tt.statements {
+			t.Errorf("wrong number of statements for %q. expected=%d, got=%d",
+				tt.input, tt.statements, len(program.Statements))
+		}
+	}
+}
@@ -0,0 +1,603 @@
+// Package printer formats Monkey programs as canonical source code: one
+// statement per line, blocks indented with tabs, single spaces around binary
+// operators and only the parentheses the precedence of the operators needs.
+// Comments between statements keep their place, as do single blank lines. A
+// comment inside a statement is attached to the closest node and printed
+// right before or after it; a // comment there is moved to the end of its
+// line, after any commas or closing brackets that follow the node.
+package printer
+
+import (
+	"ast"
+	"bytes"
+	"errors"
+	"io"
+	"lexer"
+	"parser"
+	"strconv"
+	"strings"
+	"token"
+)
+
+// Format parses src and returns it formatted. If src does not parse the error
+// lists the parser errors, one per line.
+func Format(filename string, src []byte) ([]byte, error) {
+	l := lexer.NewWithFilename(filename, string(src))
+	l.EmitComments()
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Fprint writes program to w as formatted source code, followed by a
+// newline unless the program is empty.
+func Fprint(w io.Writer, program *ast.Program) error {
+	p := &printer{
+		leading:  make(map[ast.Node][]*ast.Comment),
+		trailing: make(map[ast.Node][]*ast.Comment),
+	}
+	for _, comment := range program.Comments {
+		if !p.attach(program, comment) {
+			p.comments = append(p.comments, comment)
+		}
+	}
+	p.statements(program.Statements, token.Position{})
+	p.leadingComments(token.Position{}, p.out.Len() == 0)
+	p.endLine()
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+
+	comments []*ast.Comment // between statements, not printed yet
+	line     int            // source line the last printed statement or comment ended on, 0 if none
+
+	// comments inside statements, by the node they are printed before or after
+	leading  map[ast.Node][]*ast.Comment
+	trailing map[ast.Node][]*ast.Comment
+	// line comments printed after a node, waiting for the end of the line
+	lineComments []*ast.Comment
+}
+
+// postfix is the precedence of calls, indexing and slicing, which all bind
+// tighter than any other operator.
+const postfix = parser.CALL
+
+// primary is the precedence of expressions that never need parentheses.
+const primary = parser.INDEX + 1
+
+// newline starts a new line at the current indentation.
+func (p *printer) newline() {
+	p.endLine()
+	p.out.WriteByte('\n')
+	p.out.WriteString(strings.Repeat("\t", p.indent))
+}
+
+// separate starts a new line for the statement or comment beginning on the
+// source line at, leaving one blank line if there was at least one before it
+// in the source. first is set for the first element of a block.
+func (p *printer) separate(at token.Position, first bool) {
+	p.endLine()
+	if first {
+		if p.out.Len() > 0 {
+			p.newline()
+		}
+	} else {
+		if at.IsValid() && p.line > 0 && at.Line > p.line+1 {
+			p.out.WriteByte('\n')
+		}
+		p.newline()
+	}
+}
+
+// leadingComments prints, each on its own line, the comments that come
+// before pos in the source. All remaining comments are printed if pos is
+// invalid.
+func (p *printer) leadingComments(pos token.Position, first bool) bool {
+	for len(p.comments) > 0 && (!pos.IsValid() || p.comments[0].Pos().Offset < pos.Offset) {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+		p.separate(comment.Pos(), first)
+		p.comment(comment)
+		first = false
+	}
+	return first
+}
+
+// trailingComment prints the comment that follows the statement ending at
+// end on the same source line, if there is one before next.
+func (p *printer) trailingComment(end token.Position, next token.Position) {
+	if len(p.comments) == 0 || !end.IsValid() || len(p.lineComments) > 0 {
+		// with a line comment from inside the statement pending, the comment
+		// goes on a line of its own
+		return
+	}
+	comment := p.comments[0]
+	if comment.Pos().Line != end.Line || comment.Pos().Offset < end.Offset {
+		return
+	}
+	if next.IsValid() && next.Offset < comment.Pos().Offset {
+		return
+	}
+	p.comments = p.comments[1:]
+	p.out.WriteByte(' ')
+	p.comment(comment)
+}
+
+func (p *printer) comment(comment *ast.Comment) {
+	p.out.WriteString(strings.TrimRight(comment.Text(), " \t\r"))
+	if comment.End().Line > p.line {
+		p.line = comment.End().Line
+	}
+}
+
+// isLineComment reports whether comment runs to the end of its line.
+func isLineComment(comment *ast.Comment) bool {
+	return strings.HasPrefix(comment.Text(), "//") || strings.HasPrefix(comment.Text(), "#!")
+}
+
+// attach attaches comment to the child of the innermost node around it that
+// it is closest to, in characters. A line comment goes with the node it
+// follows on the same line. It reports false, attaching nothing, if the
+// comment is between the statements of a block.
+func (p *printer) attach(node ast.Node, comment *ast.Comment) bool {
+	var before, after ast.Node
+	for _, child := range children(node) {
+		pos, end := child.Pos(), child.End()
+		switch {
+		case !pos.IsValid() || !end.IsValid():
+			continue
+		case pos.Offset <= comment.Pos().Offset && comment.End().Offset <= end.Offset:
+			return p.attach(child, comment)
+		case end.Offset <= comment.Pos().Offset:
+			before = child
+		case after == nil && comment.End().Offset <= pos.Offset:
+			after = child
+		}
+	}
+
+	switch node.(type) {
+	case *ast.Program, *ast.BlockStatement:
+		return false
+	}
+	switch {
+	case before == nil && after == nil:
+		return false
+	case after == nil,
+		before != nil && isLineComment(comment) && comment.Pos().Line == before.End().Line,
+		before != nil && !isLineComment(comment) &&
+			comment.Pos().Offset-before.End().Offset <= after.Pos().Offset-comment.End().Offset:
+		p.trailing[before] = append(p.trailing[before], comment)
+	default:
+		p.leading[after] = append(p.leading[after], comment)
+	}
+	return true
+}
+
+// children returns the children of node in source order.
+func children(node ast.Node) []ast.Node {
+	var list []ast.Node
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == node {
+			return true
+		}
+		if n != nil {
+			list = append(list, n)
+		}
+		return false
+	})
+	return list
+}
+
+// leadingInline prints the comments attached before node, which is about to
+// be printed.
+func (p *printer) leadingInline(node ast.Node) {
+	if len(p.lineComments) > 0 {
+		p.newline()
+	}
+	for _, comment := range p.leading[node] {
+		p.comment(comment)
+		if isLineComment(comment) {
+			p.newline()
+		} else {
+			p.out.WriteByte(' ')
+		}
+	}
+	delete(p.leading, node)
+}
+
+// trailingInline prints the comments attached after node, which was just
+// printed. Line comments wait for endLine.
+func (p *printer) trailingInline(node ast.Node) {
+	for _, comment := range p.trailing[node] {
+		if isLineComment(comment) {
+			p.lineComments = append(p.lineComments, comment)
+			continue
+		}
+		p.out.WriteByte(' ')
+		p.comment(comment)
+	}
+	delete(p.trailing, node)
+}
+
+// endLine prints the line comments waiting for the end of the line.
+func (p *printer) endLine() {
+	if len(p.lineComments) > 0 && bytes.HasSuffix(p.out.Bytes(), []byte(" ")) {
+		p.out.Truncate(p.out.Len() - 1)
+	}
+	for _, comment := range p.lineComments {
+		p.out.WriteByte(' ')
+		p.comment(comment)
+	}
+	p.lineComments = nil
+}
+
+// statements prints a list of statements, each on its own line, together
+// with the comments before end, the position of the '}' closing the block.
+func (p *printer) statements(statements []ast.Statement, end token.Position) {
+	first := true
+	for i, stmt := range statements {
+		if stmt.Pos().IsValid() {
+			first = p.leadingComments(stmt.Pos(), first)
+		}
+		p.separate(stmt.Pos(), first)
+		first = false
+
+		p.statement(stmt)
+		next := end
+		if i+1 < len(statements) {
+			next = statements[i+1].Pos()
+			if needsSemicolon(stmt, statements[i+1]) {
+				p.out.WriteByte(';')
+			}
+		} else if needsSemicolon(stmt, nil) {
+			p.out.WriteByte(';')
+		}
+		if stmt.End().IsValid() {
+			p.line = stmt.End().Line
+		}
+		p.trailingComment(stmt.End(), next)
+	}
+	if end.IsValid() {
+		p.leadingComments(end, first)
+	}
+}
+
+// needsSemicolon reports whether stmt is ended by a semicolon when followed
+// by next, nil for the last statement of a block. Statements ending with a
+// block do without one, unless next would otherwise continue the expression
+// as in if (a) { f }(x).
+func needsSemicolon(stmt ast.Statement, next ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.WhileStatement, *ast.ForStatement:
+		return false
+	case *ast.ExpressionStatement:
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.TryExpression:
+			return continues(next)
+		}
+	}
+	return true
+}
+
+// continues reports whether stmt begins with a token that could also
+// continue an expression before it: '(', '[' or '-'.
+func continues(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	sub := &printer{}
+	sub.expression(es.Expression, parser.LOWEST)
+	text := sub.out.String()
+	return text != "" && strings.IndexByte("([-", text[0]) >= 0
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if stmt.Token.Type == token.CONST {
+			p.out.WriteString("const ")
+		} else {
+			p.out.WriteString("let ")
+		}
+		p.identifier(stmt.Name)
+		p.out.WriteString(" = ")
+		p.expression(stmt.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.out.WriteString("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+	case *ast.ThrowStatement:
+		p.out.WriteString("throw ")
+		p.expression(stmt.Value, parser.LOWEST)
+	case *ast.WhileStatement:
+		p.out.WriteString("while (")
+		p.expression(stmt.Condition, parser.LOWEST)
+		p.out.WriteString(") ")
+		p.block(stmt.Body)
+	case *ast.ForStatement:
+		p.out.WriteString("for (")
+		p.identifier(stmt.Variable)
+		p.out.WriteString(" in ")
+		p.expression(stmt.Iterable, parser.LOWEST)
+		p.out.WriteString(") ")
+		p.block(stmt.Body)
+	case *ast.BreakStatement:
+		p.out.WriteString("break")
+	case *ast.ContinueStatement:
+		p.out.WriteString("continue")
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+// identifier prints an identifier that is not an expression, like the name
+// of a let statement or a parameter.
+func (p *printer) identifier(id *ast.Identifier) {
+	p.leadingInline(id)
+	p.out.WriteString(id.Value)
+	p.trailingInline(id)
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	p.leadingInline(block)
+	defer p.trailingInline(block)
+
+	if len(block.Statements) == 0 && !p.hasCommentsBefore(block.Rbrace.Pos) {
+		p.out.WriteString("{}")
+		return
+	}
+	p.out.WriteString("{")
+	p.indent++
+	p.statements(block.Statements, block.Rbrace.Pos)
+	p.indent--
+	p.newline()
+	p.out.WriteString("}")
+	if block.Rbrace.End.IsValid() {
+		p.line = block.Rbrace.End.Line
+	}
+}
+
+func (p *printer) hasCommentsBefore(pos token.Position) bool {
+	return len(p.comments) > 0 && pos.IsValid() && p.comments[0].Pos().Offset < pos.Offset
+}
+
+// precedence returns how tightly expression binds its operands, which tells
+// whether it needs parentheses as the operand of another expression.
+func precedence(expression ast.Expression) int {
+	switch expression := expression.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.TokenType(expression.Operator))
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SliceExpression:
+		return postfix
+	default:
+		return primary
+	}
+}
+
+// expression prints expression with its comments, in parentheses if it
+// binds less tightly than least.
+func (p *printer) expression(expression ast.Expression, least int) {
+	p.leadingInline(expression)
+	if precedence(expression) < least {
+		p.out.WriteString("(")
+		p.bareExpression(expression)
+		p.out.WriteString(")")
+	} else {
+		p.bareExpression(expression)
+	}
+	p.trailingInline(expression)
+}
+
+func (p *printer) bareExpression(expression ast.Expression) {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(expression.Value)
+	case *ast.IntegerLiteral:
+		switch {
+		case expression.Token.Literal != "":
+			p.out.WriteString(expression.Token.Literal)
+		case expression.Big != nil:
+			p.out.WriteString(expression.Big.String())
+		default:
+			p.out.WriteString(strconv.FormatInt(expression.Value, 10))
+		}
+	case *ast.FloatLiteral:
+		if expression.Token.Literal != "" {
+			p.out.WriteString(expression.Token.Literal)
+		} else {
+			p.out.WriteString(formatFloat(expression.Value))
+		}
+	case *ast.StringLiteral:
+		p.out.WriteString(lexer.Quote(expression.Value))
+	case *ast.InterpolatedString:
+		p.out.WriteString(`"`)
+		for _, part := range expression.Parts {
+			if text, ok := part.(*ast.StringLiteral); ok {
+				quoted := lexer.Quote(text.Value)
+				p.out.WriteString(quoted[1 : len(quoted)-1])
+				continue
+			}
+			p.out.WriteString("${")
+			p.expression(part, parser.LOWEST)
+			p.out.WriteString("}")
+		}
+		p.out.WriteString(`"`)
+	case *ast.Boolean:
+		p.out.WriteString(strconv.FormatBool(expression.Value))
+	case *ast.PrefixExpression:
+		p.out.WriteString(expression.Operator)
+		if right, ok := expression.Right.(*ast.PrefixExpression); ok && right.Operator == "-" && expression.Operator == "-" {
+			// - -x, not --x
+			p.out.WriteByte(' ')
+		}
+		p.expression(expression.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		// operators are left associative: a - b - c is (a - b) - c
+		operator := precedence(expression)
+		p.expression(expression.Left, operator)
+		p.out.WriteString(" " + expression.Operator + " ")
+		p.expression(expression.Right, operator+1)
+	case *ast.AssignExpression:
+		// assignment is right associative: a = b = c is a = (b = c)
+		p.expression(expression.Target, postfix)
+		p.out.WriteString(" " + expression.Operator + " ")
+		p.expression(expression.Value, parser.ASSIGN)
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.expression(expression.Condition, parser.LOWEST)
+		p.out.WriteString(") ")
+		p.block(expression.Consequence)
+		if expression.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(expression.Alternative)
+		}
+	case *ast.TryExpression:
+		p.out.WriteString("try ")
+		p.block(expression.Block)
+		if expression.Catch != nil {
+			p.out.WriteString(" catch (")
+			p.identifier(expression.Parameter)
+			p.out.WriteString(") ")
+			p.block(expression.Catch)
+		}
+		if expression.Finally != nil {
+			p.out.WriteString(" finally ")
+			p.block(expression.Finally)
+		}
+	case *ast.FunctionLiteral:
+		p.out.WriteString("fn(")
+		for i, parameter := range expression.Parameters {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			if expression.Variadic && i == len(expression.Parameters)-1 {
+				p.out.WriteString("...")
+			}
+			p.identifier(parameter)
+			if value := expression.Default(i); value != nil {
+				p.out.WriteString(" = ")
+				p.expression(value, parser.LOWEST)
+			}
+		}
+		p.out.WriteString(") ")
+		p.block(expression.Body)
+	case *ast.CallExpression:
+		p.expression(expression.Function, postfix)
+		p.out.WriteString("(")
+		p.list(expression.Arguments, false)
+		p.out.WriteString(")")
+	case *ast.SpreadExpression:
+		p.out.WriteString("...")
+		p.expression(expression.Value, parser.LOWEST)
+	case *ast.ArrayLiteral:
+		p.out.WriteString("[")
+		p.list(expression.Elements, spansLines(expression))
+		p.out.WriteString("]")
+	case *ast.IndexExpression:
+		p.expression(expression.Left, postfix)
+		p.out.WriteString("[")
+		p.expression(expression.Index, parser.LOWEST)
+		p.out.WriteString("]")
+	case *ast.SliceExpression:
+		p.expression(expression.Left, postfix)
+		p.out.WriteString("[")
+		if expression.Low != nil {
+			p.expression(expression.Low, parser.LOWEST)
+		}
+		p.out.WriteString(":")
+		if expression.High != nil {
+			p.expression(expression.High, parser.LOWEST)
+		}
+		p.out.WriteString("]")
+	case *ast.HashLiteral:
+		p.hash(expression)
+	}
+}
+
+// list prints comma separated expressions, one per line if multiline.
+func (p *printer) list(expressions []ast.Expression, multiline bool) {
+	if multiline && len(expressions) > 0 {
+		p.indent++
+		for i, expression := range expressions {
+			if i > 0 {
+				p.out.WriteString(",")
+			}
+			p.newline()
+			p.expression(expression, parser.LOWEST)
+		}
+		p.indent--
+		p.newline()
+		return
+	}
+	for i, expression := range expressions {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(expression, parser.LOWEST)
+	}
+}
+
+// hash prints a hash literal on one line, or with a pair per line and a
+// trailing comma if it spans several lines in the source.
+func (p *printer) hash(hash *ast.HashLiteral) {
+	p.out.WriteString("{")
+	if spansLines(hash) && len(hash.Pairs) > 0 {
+		p.indent++
+		for _, pair := range hash.Pairs {
+			p.newline()
+			p.pair(pair)
+			p.out.WriteString(",")
+		}
+		p.indent--
+		p.newline()
+	} else {
+		for i, pair := range hash.Pairs {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.pair(pair)
+		}
+	}
+	p.out.WriteString("}")
+}
+
+func (p *printer) pair(pair ast.HashPair) {
+	p.expression(pair.Key, parser.LOWEST)
+	p.out.WriteString(": ")
+	p.expression(pair.Value, parser.LOWEST)
+}
+
+// spansLines reports whether node was written on several lines.
+func spansLines(node ast.Node) bool {
+	return node.Pos().IsValid() && node.End().IsValid() && node.Pos().Line != node.End().Line
+}
+
+// formatFloat formats a float so that it reads back as a float literal.
+func formatFloat(value float64) string {
+	s := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,136 @@
+package printer
+
+import (
+	"bytes"
+	"lexer"
+	"parser"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let  x=1", "let x = 1;\n"},
+		{"const x = 1; x", "const x = 1;\nx;\n"},
+		{"let add = fn(a,b){a+b}", "let add = fn(a, b) {\n\ta + b;\n};\n"},
+		{"fn(a, b = 2, ...rest) {}", "fn(a, b = 2, ...rest) {};\n"},
+		{"if (x) { 1 } else { 2 }", "if (x) {\n\t1;\n} else {\n\t2;\n}\n"},
+		{"while (x < 10) { x += 1 }", "while (x < 10) {\n\tx += 1;\n}\n"},
+		{"for (x in xs) { if (x) { break } continue }", "for (x in xs) {\n\tif (x) {\n\t\tbreak;\n\t}\n\tcontinue;\n}\n"},
+		{"try { throw 1 } catch (e) { e } finally { 2 }", "try {\n\tthrow 1;\n} catch (e) {\n\te;\n} finally {\n\t2;\n}\n"},
+
+		// Parentheses are kept where precedence needs them and dropped
+		// everywhere else.
+		{"(a + b) * c", "(a + b) * c;\n"},
+		{"a + (b * c)", "a + b * c;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"(a - b) - c", "a - b - c;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"(-a) + b", "-a + b;\n"},
+		{"(-f)(x)", "(-f)(x);\n"},
+		{"(fn(x) { x })(1)", "fn(x) {\n\tx;\n}(1);\n"},
+		{"(a + b)[0]", "(a + b)[0];\n"},
+		{"a[1:(2 + 3)]", "a[1:2 + 3];\n"},
+		{"a[:]", "a[:];\n"},
+		{"1 + (x = 2)", "1 + (x = 2);\n"},
+		{"x = (y = 3)", "x = y = 3;\n"},
+		{"!(a == b)", "!(a == b);\n"},
+		{"-(-x)", "- -x;\n"},
+		{"!(-x)", "!-x;\n"},
+		{"(a < b) == (c > d)", "a < b == c > d;\n"},
+
+		// Literals keep their spelling.
+		{"1.50 + 2", "1.50 + 2;\n"},
+		{`"a\tb\"c"`, "\"a\\tb\\\"c\";\n"},
+		{`"sum: ${ a+b }!"`, "\"sum: ${a + b}!\";\n"},
+		{`"\${x}"`, "\"\\${x}\";\n"},
+		{"[1,2 ,3]", "[1, 2, 3];\n"},
+		{"[\n1,\n2]", "[\n\t1,\n\t2\n];\n"},
+		{`{"a":1,"b":2}`, "{\"a\": 1, \"b\": 2};\n"},
+		{"let h = {\"a\": 1,\n\"b\": {}}", "let h = {\n\t\"a\": 1,\n\t\"b\": {},\n};\n"},
+
+		// Comments stay where they were, blank lines are collapsed to one.
+		{"#!/usr/bin/env monkey run\nlet x = 1", "#!/usr/bin/env monkey run\nlet x = 1;\n"},
+		{"// one\n// two\nx", "// one\n// two\nx;\n"},
+		{"x // trailing\ny", "x; // trailing\ny;\n"},
+		{"x\n\n\n\ny", "x;\n\ny;\n"},
+		{"x\n\n// about y\ny", "x;\n\n// about y\ny;\n"},
+		{"x\n/* last */", "x;\n/* last */\n"},
+		{"/* only */", "/* only */\n"},
+		{"fn() {\n// nothing yet\n}", "fn() {\n\t// nothing yet\n};\n"},
+		{"if (x) { y /* why */ }", "if (x) {\n\ty; /* why */\n}\n"},
+		{"if (x) { y } /* after */", "if (x) {\n\ty;\n} /* after */\n"},
+		{"if (x) { /* a */ } else { y }", "if (x) {\n\t/* a */\n} else {\n\ty;\n}\n"},
+
+		// Comments inside a statement stay next to the closest node; a line
+		// comment is moved past the commas and brackets up to the line end.
+		{"[1, // one\n 2]", "[\n\t1, // one\n\t2\n];\n"},
+		{"[\n// first\n1,\n2 // two\n]", "[\n\t// first\n\t1,\n\t2 // two\n];\n"},
+		{"let h = {\n\"a\": 1, // one\n\"b\": 2\n}", "let h = {\n\t\"a\": 1, // one\n\t\"b\": 2,\n};\n"},
+		{"fn(a /* the a */, b) { a }", "fn(a /* the a */, b) {\n\ta;\n};\n"},
+		{"fn(a, /* the b */ b) { a }", "fn(a, /* the b */ b) {\n\ta;\n};\n"},
+		{"puts(1 /* x */ + 2)", "puts(1 /* x */ + 2);\n"},
+		{"puts(1 + /* x */ 2)", "puts(1 + /* x */ 2);\n"},
+		{"let x /* name */ = 1", "let x /* name */ = 1;\n"},
+		{"if (x) /* c */ { y }", "if (x) /* c */ {\n\ty;\n}\n"},
+		{"f(1, // one\n2)", "f(1, // one\n2);\n"},
+		{"f(1 // one\n) // two\nx", "f(1); // one\n// two\nx;\n"},
+
+		// A statement ending with a block keeps its semicolon when the next
+		// one would otherwise continue it.
+		{"if (x) { 1 }; (y)", "if (x) {\n\t1;\n}\ny;\n"},
+		{"if (x) { 1 }; (-f)(y)", "if (x) {\n\t1;\n};\n(-f)(y);\n"},
+		{"if (x) { 1 }; -y", "if (x) {\n\t1;\n};\n-y;\n"},
+		{"if (x) { 1 }; [y]", "if (x) {\n\t1;\n};\n[y];\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			formatted, err := Format("test.mk", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Format failed: %s", err)
+			}
+			if string(formatted) != tt.expected {
+				t.Fatalf("formatted source wrong.\nexpected=%q\ngot=     %q", tt.expected, formatted)
+			}
+
+			again, err := Format("test.mk", formatted)
+			if err != nil {
+				t.Fatalf("formatted source does not parse: %s", err)
+			}
+			if !bytes.Equal(again, formatted) {
+				t.Fatalf("Format is not idempotent.\nfirst= %q\nsecond=%q", formatted, again)
+			}
+
+			if original, reparsed := parse(t, tt.input), parse(t, string(formatted)); original != reparsed {
+				t.Fatalf("formatting changed the program.\nbefore=%q\nafter= %q", original, reparsed)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	_, err := Format("test.mk", []byte("let = 1;\nlet y 2;"))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	expected := "test.mk:1:5: expected next token to be IDENT, got = instead\n" +
+		"test.mk:2:7: expected next token to be =, got INT instead"
+	if err.Error() != expected {
+		t.Fatalf("error wrong.\nexpected=%q\ngot=     %q", expected, err.Error())
+	}
+}
+
+// parse returns the String() of the program in input, which shows its
+// structure fully parenthesized.
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program.String()
+}
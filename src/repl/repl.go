@@ -1,23 +1,49 @@
 package repl
 
 import (
-	"evaluator"
+	"ast"
+	"checker"
+	"engine"
 	"lexer"
 	"object"
 	"parser"
+	"token"
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT is shown while the input so far is an incomplete statement.
+const CONTINUATION_PROMPT = ".. "
+
+// Start runs the REPL with the tree-walking evaluator.
 func Start(in io.Reader, out io.Writer) {
+	StartWithEngine(in, out, engine.Eval)
+}
+
+// StartWithEngine runs the REPL with the named engine, see engine.New.
+func StartWithEngine(in io.Reader, out io.Writer, engineName string) {
+	runner, err := engine.New(engineName)
+	if err != nil {
+		io.WriteString(out, err.Error()+"\n")
+		return
+	}
+
+	// each input is checked against the names that earlier ones declared
+	check := checker.New()
+
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	var lines []string
 
 	for {
-		fmt.Printf(PROMPT)
+		if len(lines) == 0 {
+			io.WriteString(out, PROMPT)
+		} else {
+			io.WriteString(out, CONTINUATION_PROMPT)
+		}
 		scanned := scanner.Scan()
 
 		if !scanned {
@@ -25,25 +51,117 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
+		if len(lines) > 0 && strings.TrimSpace(line) == "" {
+			// an empty continuation line submits the input as it is, so a
+			// mistyped statement can always be escaped
+		} else {
+			lines = append(lines, line)
+			if IsIncomplete(strings.Join(lines, "\n")) {
+				continue
+			}
+		}
+
+		input := strings.Join(lines, "\n")
+		lines = nil
+
+		l := lexer.New(input)
 		p := parser.New(l)
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
 			printParserErrors(out, p.Errors())
 			continue
 		}
+		if errors := check.Check(program); len(errors) != 0 {
+			printParserErrors(out, errors)
+			continue
+		}
 		io.WriteString(out, program.String())
 		io.WriteString(out, "\n")
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated, err := run(runner, program)
+		if err != nil {
+			io.WriteString(out, "\t"+err.Error()+"\n")
+			continue
+		}
+		check.Declare(program, runner.Bound)
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, err.Traceback())
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
 	}
 }
+
+// run runs program and turns a panic of the engine into an error, so that a
+// bug in the interpreter does not end the session.
+func run(runner engine.Engine, program *ast.Program) (evaluated object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			evaluated, err = nil, fmt.Errorf("internal error: %v", r)
+		}
+	}()
+	return runner.Run(program)
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
 }
+
+// trailingOperators are tokens that cannot end a statement because they
+// still expect an operand.
+var trailingOperators = map[token.TokenType]bool{
+	token.ASSIGN:          true,
+	token.PLUS:            true,
+	token.MINUS:           true,
+	token.BANG:            true,
+	token.ASTERISK:        true,
+	token.SLASH:           true,
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN:    true,
+	token.LT:              true,
+	token.GT:              true,
+	token.LE:              true,
+	token.GE:              true,
+	token.AND:             true,
+	token.OR:              true,
+	token.EQ:              true,
+	token.NE:              true,
+	token.COMMA:           true,
+	token.COLON:           true,
+	token.ELSE:            true,
+}
+
+// IsIncomplete reports whether input needs more lines to form a complete
+// statement: it has unclosed parentheses, braces or brackets, an unterminated
+// string or block comment, or ends with an operator.
+func IsIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	var last token.Token
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.TEMPLATE_HEAD:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET, token.TEMPLATE_TAIL:
+			depth--
+		case token.ILLEGAL:
+			if tok.Literal == lexer.UnterminatedString || tok.Literal == lexer.UnterminatedComment {
+				return true
+			}
+		}
+		last = tok
+	}
+
+	if depth > 0 {
+		return true
+	}
+	return trailingOperators[last.Type]
+}
@@ -0,0 +1,139 @@
+package repl
+
+import (
+	"ast"
+	"bytes"
+	"engine"
+	"object"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let a = 5;", false},
+		{"", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n  a + b\n}", false},
+		{"add(1,", true},
+		{"add(1, 2", true},
+		{"[1, 2", true},
+		{"{\"a\": 1", true},
+		{"1 +", true},
+		{"a &&", true},
+		{"let a =", true},
+		{"if (true) { 1 } else", true},
+		{"\"hello", true},
+		{"\"hello\"", false},
+		{"\"a\\\"", true},
+		{"\"a\\qb\"", false},
+		{"\"a ${x", true},
+		{"\"a ${f(1,", true},
+		{"\"a ${x} b", true},
+		{"\"a ${x} b\"", false},
+		{"1 + /* a", true},
+		{"1 // a", false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsIncomplete(tt.input); got != tt.expected {
+			t.Errorf("IsIncomplete(%q) = %t, want %t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestStartMultiLine(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nadd(1,\n2)\n1 +\n\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := []string{
+		PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT,
+		PROMPT + CONTINUATION_PROMPT,
+		"3",
+		PROMPT + CONTINUATION_PROMPT,
+	}
+	got := out.String()
+	for _, e := range expected {
+		if !strings.Contains(got, e) {
+			t.Errorf("output does not contain %q. got=%q", e, got)
+		}
+	}
+}
+
+func TestStartTraceback(t *testing.T) {
+	// each input is parsed on its own, so positions restart on every prompt
+	input := "let f = fn(x) {\n  x + true\n};\nf(1)\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := "2:3: TypeError: Type mismatch: INTEGER + BOOLEAN\n    at f (2:3)\n    at <main> (1:1)\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("output does not contain %q. got=%q", expected, out.String())
+	}
+}
+
+func TestStartConst(t *testing.T) {
+	// the checker rejects an input before it runs, also when it redeclares a
+	// name of an earlier input; an input declares only the names it bound
+	input := "const b = 1; puts(\"ran\"); b = 2\nconst a = 1\nlet a = 2\nlet c = 1\nlet c = 2\nlet d = 1 / 0\nlet d = 3; d\n" +
+		"let e = 1; 1 / 0\nlet e = 2\nif (false) { let f = 1 }\nlet f = 4; f\n"
+	for _, engineName := range []string{engine.Eval, engine.VM} {
+		var out bytes.Buffer
+		StartWithEngine(strings.NewReader(input), &out, engineName)
+
+		got := out.String()
+		for _, e := range []string{
+			"\t1:27: cannot assign to constant b (declared at 1:7)\n",
+			"\t1:5: cannot redeclare constant a (declared at 1:7)\n",
+			"\t1:5: cannot redeclare variable c (declared at 1:5)\n",
+			"ZeroDivisionError: division by zero: 1 / 0\n",
+			"let d = 3;d\n3\n",
+			"\t1:5: cannot redeclare variable e (declared at 1:5)\n",
+			"let f = 4;f\n4\n",
+		} {
+			if !strings.Contains(got, e) {
+				t.Errorf("%s: output does not contain %q. got=%q", engineName, e, got)
+			}
+		}
+		if strings.Contains(got, "ran") {
+			t.Errorf("%s: input with checker errors was executed. got=%q", engineName, got)
+		}
+	}
+}
+
+func TestStartZeroDivision(t *testing.T) {
+	for _, engineName := range []string{engine.Eval, engine.VM} {
+		input := "5 / 0\n1 + 1\n"
+		var out bytes.Buffer
+		StartWithEngine(strings.NewReader(input), &out, engineName)
+
+		got := out.String()
+		for _, e := range []string{"ZeroDivisionError: division by zero: 5 / 0\n", "2\n"} {
+			if !strings.Contains(got, e) {
+				t.Errorf("%s: output does not contain %q. got=%q", engineName, e, got)
+			}
+		}
+	}
+}
+
+type panickingEngine struct{}
+
+func (panickingEngine) Run(program *ast.Program) (object.Object, error) {
+	panic("boom")
+}
+
+func (panickingEngine) Define(name string, value object.Object) {}
+
+func (panickingEngine) Bound(name string) bool { return false }
+
+func TestRunRecovers(t *testing.T) {
+	_, err := run(panickingEngine{}, &ast.Program{})
+	if err == nil || err.Error() != "internal error: boom" {
+		t.Errorf("panic is not turned into an error. got=%v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package vm
+
+import (
+	"code"
+	"object"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	locals      *object.Locals // nil for the top level program
+	basePointer int
+	blocks      []block // try expressions and loops being executed, innermost last
+}
+
+type blockKind int
+
+const (
+	tryBlock blockKind = iota
+	loopBlock
+)
+
+type tryState int
+
+const (
+	inTry tryState = iota
+	inCatch
+	inFinally
+)
+
+// block is a try expression being executed by a frame, from its OpSetupTry
+// until its OpEndTry or, if it has a finally block, its OpEndFinally, or a
+// loop, from its OpSetupLoop until its OpPopLoop or a break.
+type block struct {
+	kind blockKind
+	sp   int // stack pointer at OpSetupTry or OpSetupLoop
+
+	catchIP   int // 0 if there is no catch block
+	finallyIP int // 0 if there is no finally block
+	state     tryState
+	pending   object.Object // the error, return, break or continue to resume after the finally block
+
+	breakIP    int
+	continueIP int
+}
+
+func NewFrame(cl *object.Closure, locals *object.Locals, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, locals: locals, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,763 @@
+package vm
+
+import (
+	"code"
+	"compiler"
+	"evaluator"
+	"fmt"
+	"math"
+	"object"
+)
+
+// StackSize is the initial size of the stack, which grows as needed.
+const StackSize = 2048
+const GlobalsSize = 65536
+
+// Integers in this range are preallocated so that loop counters and small
+// arithmetic results do not allocate.
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 1024
+)
+
+var cachedIntegers [maxCachedInteger - minCachedInteger + 1]*object.Integer
+
+func init() {
+	for i := range cachedIntegers {
+		cachedIntegers[i] = &object.Integer{Value: int64(i + minCachedInteger)}
+	}
+}
+
+func newInteger(value int64) *object.Integer {
+	if minCachedInteger <= value && value <= maxCachedInteger {
+		return cachedIntegers[value-minCachedInteger]
+	}
+	return &object.Integer{Value: value}
+}
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // always points to the next free slot; top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	result object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsState(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsState creates a VM that shares globals with earlier runs, as
+// the REPL does line by line.
+func NewWithGlobalsState(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, nil, 0)
+
+	frames := []*Frame{mainFrame}
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// Result returns the value of the program after Run: the value of its last
+// expression statement or return, the *object.Error that stopped it, or nil
+// when the program ends with a statement that produces no value.
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+// Run executes the bytecode. Monkey runtime errors do not make Run fail; they
+// stop execution and become the Result, exactly like evaluator.Eval returns
+// them. A non-nil error means the bytecode itself is malformed.
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual:
+			err = vm.executeBinaryOperation(op)
+
+		case code.OpMinus:
+			operand := vm.pop()
+			if integer, ok := operand.(*object.Integer); ok && isSmallInteger(integer.Value) {
+				err = vm.push(newInteger(-integer.Value))
+			} else {
+				err = vm.pushResult(evaluator.EvalPrefixOperator("-", operand))
+			}
+
+		case code.OpBang:
+			err = vm.pushResult(evaluator.EvalPrefixOperator("!", vm.pop()))
+
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+
+		case code.OpJump:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = position - 1
+
+		case code.OpJumpNotTruthy:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = position - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				err = identifierNotFound(vm.globalNames, int(globalIndex))
+			} else {
+				err = vm.push(value)
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.pushLocal(vm.currentFrame().locals, int(localIndex))
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.currentFrame().locals.Values[localIndex] = vm.pop()
+
+		case code.OpGetOuter:
+			depth := code.ReadUint16(ins[ip+1:])
+			localIndex := code.ReadUint16(ins[ip+3:])
+			vm.currentFrame().ip += 4
+
+			err = vm.pushLocal(vm.outerLocals(int(depth)), int(localIndex))
+
+		case code.OpSetOuter:
+			depth := code.ReadUint16(ins[ip+1:])
+			localIndex := code.ReadUint16(ins[ip+3:])
+			vm.currentFrame().ip += 4
+
+			vm.outerLocals(int(depth)).Values[localIndex] = vm.pop()
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if vm.globals[globalIndex] == nil {
+				err = undeclaredAssignment(vm.globalNames, int(globalIndex))
+			} else {
+				vm.globals[globalIndex] = vm.stack[vm.sp-1]
+			}
+
+		case code.OpAssignLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.assignLocal(vm.currentFrame().locals, int(localIndex))
+
+		case code.OpAssignOuter:
+			depth := code.ReadUint16(ins[ip+1:])
+			localIndex := code.ReadUint16(ins[ip+3:])
+			vm.currentFrame().ip += 4
+
+			err = vm.assignLocal(vm.outerLocals(int(depth)), int(localIndex))
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := evaluator.Interpolate(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp = vm.sp - numParts
+
+			err = vm.push(str)
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash := object.NewHash()
+			for i := vm.sp - numElements; i < vm.sp && err == nil; i += 2 {
+				err = evaluator.SetHashPair(hash, vm.stack[i], vm.stack[i+1])
+			}
+			vm.sp = vm.sp - numElements
+
+			if err == nil {
+				err = vm.push(hash)
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(evaluator.EvalIndex(left, index))
+
+		case code.OpSlice:
+			flags := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			var low, high object.Object
+			if flags&code.SliceHigh != 0 {
+				high = vm.pop()
+			}
+			if flags&code.SliceLow != 0 {
+				low = vm.pop()
+			}
+			left := vm.pop()
+
+			err = vm.pushResult(evaluator.EvalSlice(left, low, high))
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err = evaluator.SetIndex(left, index, value); err == nil {
+				err = vm.push(value)
+			}
+
+		case code.OpDup:
+			n := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			start := vm.sp - n
+			for i := 0; i < n && err == nil; i++ {
+				err = vm.push(vm.stack[start+i])
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+			}
+			err = vm.push(&object.Closure{Fn: fn, Env: vm.currentFrame().locals})
+
+		case code.OpCall:
+			numArgs := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.executeCall(int(numArgs))
+
+		case code.OpCallSpread:
+			numParts := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.executeSpreadCall(int(numParts))
+
+		case code.OpJumpIfBound:
+			index := code.ReadUint16(ins[ip+1:])
+			frame := vm.currentFrame()
+			if frame.locals.Values[index] != nil {
+				frame.ip = int(code.ReadUint16(ins[ip+3:])) - 1
+			} else {
+				frame.ip += 4
+			}
+
+		case code.OpReturnValue:
+			var done bool
+			done, err = vm.returnValue(vm.pop())
+			if done {
+				return nil
+			}
+
+		case code.OpThrow:
+			err = evaluator.Throw(vm.pop())
+
+		case code.OpSetupTry:
+			catchIP := int(code.ReadUint16(ins[ip+1:]))
+			finallyIP := int(code.ReadUint16(ins[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			frame := vm.currentFrame()
+			frame.blocks = append(frame.blocks, block{kind: tryBlock, sp: vm.sp, catchIP: catchIP, finallyIP: finallyIP})
+
+		case code.OpEndTry:
+			frame := vm.currentFrame()
+			b := &frame.blocks[len(frame.blocks)-1]
+			if b.finallyIP != 0 {
+				b.state = inFinally
+			} else {
+				frame.blocks = frame.blocks[:len(frame.blocks)-1]
+			}
+
+		case code.OpEndFinally:
+			frame := vm.currentFrame()
+			b := frame.blocks[len(frame.blocks)-1]
+			frame.blocks = frame.blocks[:len(frame.blocks)-1]
+
+			switch pending := b.pending.(type) {
+			case *object.Error:
+				vm.pop()
+				err = pending
+			case *object.ReturnValue:
+				vm.pop()
+				var done bool
+				done, err = vm.returnValue(pending.Value)
+				if done {
+					return nil
+				}
+			case *object.Break, *object.Continue:
+				vm.pop()
+				vm.loopControl(pending)
+			}
+
+		case code.OpSetupLoop:
+			breakIP := int(code.ReadUint16(ins[ip+1:]))
+			continueIP := int(code.ReadUint16(ins[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			frame := vm.currentFrame()
+			frame.blocks = append(frame.blocks, block{kind: loopBlock, sp: vm.sp, breakIP: breakIP, continueIP: continueIP})
+
+		case code.OpPopLoop:
+			frame := vm.currentFrame()
+			frame.blocks = frame.blocks[:len(frame.blocks)-1]
+
+		case code.OpBreak:
+			vm.loopControl(evaluator.BREAK)
+
+		case code.OpContinue:
+			vm.loopControl(evaluator.CONTINUE)
+
+		case code.OpIterator:
+			iterator, iteratorErr := evaluator.NewIterator(vm.pop())
+			if iteratorErr != nil {
+				err = iteratorErr
+			} else {
+				err = vm.push(iterator)
+			}
+
+		case code.OpNext:
+			exitIP := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterator := vm.stack[vm.sp-1].(*object.Iterator)
+			if element, ok := iterator.Next(); ok {
+				err = vm.push(element)
+			} else {
+				vm.currentFrame().ip = exitIP - 1
+			}
+
+		default:
+			return fmt.Errorf("opcode %d undefined", op)
+		}
+
+		if err != nil {
+			if !err.Pos.IsValid() {
+				// raised just now rather than resumed after a finally block
+				span := vm.currentFrame().cl.Fn.Positions[ip]
+				err.Pos, err.End = span.Pos, span.End
+				err.Stack = vm.stackTrace()
+			}
+			if !vm.raise(err) {
+				vm.result = err
+				return nil
+			}
+		}
+	}
+
+	if vm.sp > 0 {
+		vm.result = vm.stack[vm.sp-1]
+	}
+	return nil
+}
+
+// returnValue returns value from the current function, or ends the program at
+// the top level. The finally blocks of the try expressions it leaves run first,
+// in which case the return resumes at their OpEndFinally. done reports whether
+// the program has ended.
+func (vm *VM) returnValue(value object.Object) (done bool, err *object.Error) {
+	frame := vm.currentFrame()
+	for len(frame.blocks) > 0 {
+		b := &frame.blocks[len(frame.blocks)-1]
+		if b.finallyIP != 0 && b.state != inFinally {
+			vm.enterFinally(frame, b, &object.ReturnValue{Value: value})
+			return false, nil
+		}
+		frame.blocks = frame.blocks[:len(frame.blocks)-1]
+	}
+
+	vm.popFrame()
+	if vm.framesIndex == 0 {
+		vm.result = value
+		return true, nil
+	}
+	vm.sp = frame.basePointer
+
+	return false, vm.push(value)
+}
+
+// raise unwinds the frames to the innermost try expression that handles err
+// and continues in its catch or finally block. It reports false if no try
+// expression is left, in which case err ends the program.
+func (vm *VM) raise(err *object.Error) bool {
+	for {
+		frame := vm.currentFrame()
+		for len(frame.blocks) > 0 {
+			b := &frame.blocks[len(frame.blocks)-1]
+			switch {
+			case b.state == inTry && b.catchIP != 0:
+				b.state = inCatch
+				vm.sp = b.sp
+				vm.push(evaluator.CaughtValue(err)) // cannot overflow, sp only went down
+				frame.ip = b.catchIP - 1
+				return true
+			case b.state != inFinally && b.finallyIP != 0:
+				vm.enterFinally(frame, b, err)
+				return true
+			}
+			frame.blocks = frame.blocks[:len(frame.blocks)-1]
+		}
+
+		if vm.framesIndex == 1 {
+			return false
+		}
+		vm.popFrame()
+		vm.sp = frame.basePointer
+	}
+}
+
+// loopControl continues a break or continue at the innermost loop of the
+// current frame. The finally blocks of the try expressions it leaves run
+// first, in which case it resumes at their OpEndFinally.
+func (vm *VM) loopControl(control object.Object) {
+	frame := vm.currentFrame()
+	for {
+		b := &frame.blocks[len(frame.blocks)-1]
+		if b.kind == loopBlock {
+			vm.sp = b.sp
+			if control == evaluator.BREAK {
+				frame.blocks = frame.blocks[:len(frame.blocks)-1]
+				frame.ip = b.breakIP - 1
+			} else {
+				frame.ip = b.continueIP - 1
+			}
+			return
+		}
+		if b.finallyIP != 0 && b.state != inFinally {
+			vm.enterFinally(frame, b, control)
+			return
+		}
+		frame.blocks = frame.blocks[:len(frame.blocks)-1]
+	}
+}
+
+// enterFinally jumps to the finally block of b, to resume pending once the
+// block is done. NULL takes the place of the value of the try expression.
+func (vm *VM) enterFinally(frame *Frame, b *block, pending object.Object) {
+	b.state = inFinally
+	b.pending = pending
+	vm.sp = b.sp
+	vm.push(evaluator.NULL) // cannot overflow, sp only went down
+	frame.ip = b.finallyIP - 1
+}
+
+// isSmallInteger reports whether value fits into 32 bits, so that sums and
+// products of two such values fit into int64.
+func isSmallInteger(value int64) bool {
+	return math.MinInt32 <= value && value <= math.MaxInt32
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) *object.Error {
+	right := vm.pop()
+	left := vm.pop()
+
+	// the fast path only takes operands whose results cannot overflow; the
+	// evaluator promotes everything else to big integers or floats and
+	// reports division by zero
+	leftInteger, leftOk := left.(*object.Integer)
+	rightInteger, rightOk := right.(*object.Integer)
+	if !(leftOk && rightOk && isSmallInteger(leftInteger.Value) && isSmallInteger(rightInteger.Value)) ||
+		op == code.OpDiv && rightInteger.Value == 0 {
+		return vm.pushResult(evaluator.EvalInfixOperator(infixOperators[op], left, right))
+	}
+
+	leftValue := leftInteger.Value
+	rightValue := rightInteger.Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(newInteger(leftValue + rightValue))
+	case code.OpSub:
+		return vm.push(newInteger(leftValue - rightValue))
+	case code.OpMul:
+		return vm.push(newInteger(leftValue * rightValue))
+	case code.OpDiv:
+		return vm.push(newInteger(leftValue / rightValue))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	}
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		fn := callee.Fn
+		if numArgs < fn.MinArguments || !fn.Variadic && numArgs > fn.NumParameters {
+			most := fn.NumParameters
+			if fn.Variadic {
+				most = -1
+			}
+			return evaluator.ArityError(functionName(fn), fn.MinArguments, most, numArgs)
+		}
+		// the main frame is not a call
+		if vm.framesIndex > evaluator.MaxCallDepth {
+			return newError(object.RuntimeError, "stack overflow")
+		}
+
+		locals := &object.Locals{
+			Values: make([]object.Object, fn.NumLocals),
+			Names:  fn.LocalNames,
+			Outer:  callee.Env,
+		}
+		// parameters without an argument stay unset for OpJumpIfBound
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		if fn.Variadic {
+			rest := fn.NumParameters - 1
+			elements := []object.Object{}
+			if numArgs > rest {
+				elements = append(elements, args[rest:]...)
+				args = args[:rest]
+			}
+			locals.Values[rest] = &object.Array{Elements: elements}
+		}
+		copy(locals.Values, args)
+		vm.sp = vm.sp - numArgs - 1
+
+		vm.pushFrame(NewFrame(callee, locals, vm.sp))
+		return nil
+
+	case *object.Buildin:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp = vm.sp - numArgs - 1
+
+		return vm.pushResult(callee.Fn(args...))
+
+	default:
+		return newError(object.TypeError, "not a function: %s", callee.Type())
+	}
+}
+
+// executeSpreadCall calls the function below numParts arrays on the stack
+// with their elements as the arguments.
+func (vm *VM) executeSpreadCall(numParts int) *object.Error {
+	args := []object.Object{}
+	for _, part := range vm.stack[vm.sp-numParts : vm.sp] {
+		array, ok := part.(*object.Array)
+		if !ok {
+			return evaluator.SpreadError(part)
+		}
+		args = append(args, array.Elements...)
+	}
+	vm.sp -= numParts
+
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return err
+		}
+	}
+	return vm.executeCall(len(args))
+}
+
+// outerLocals returns the locals of the function depth levels up from the current one.
+func (vm *VM) outerLocals(depth int) *object.Locals {
+	locals := vm.currentFrame().locals
+	for i := 0; i < depth; i++ {
+		locals = locals.Outer
+	}
+	return locals
+}
+
+func (vm *VM) pushLocal(locals *object.Locals, index int) *object.Error {
+	value := locals.Values[index]
+	if value == nil {
+		// declared in this function but its let has not run yet
+		return identifierNotFound(locals.Names, index)
+	}
+	return vm.push(value)
+}
+
+// stackTrace describes the active calls of Monkey functions, innermost first.
+func (vm *VM) stackTrace() []object.StackFrame {
+	var stack []object.StackFrame
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		// the caller is suspended on the last byte of the operand of its OpCall
+		caller := vm.frames[i-1]
+		stack = append(stack, object.StackFrame{
+			Function: functionName(vm.frames[i].cl.Fn),
+			Pos:      caller.cl.Fn.Positions[caller.ip-2].Pos,
+		})
+	}
+	return stack
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Literal == nil || fn.Literal.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Literal.Name
+}
+
+// assignLocal stores the top of the stack, which stays there, in a local slot.
+func (vm *VM) assignLocal(locals *object.Locals, index int) *object.Error {
+	if locals.Values[index] == nil {
+		return undeclaredAssignment(locals.Names, index)
+	}
+	locals.Values[index] = vm.stack[vm.sp-1]
+	return nil
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// pushResult pushes the result of a shared evaluator operation, or returns it
+// if it is an error.
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if err, ok := o.(*object.Error); ok {
+		return err
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
+
+func identifierNotFound(names []string, index int) *object.Error {
+	return newError(object.NameError, "Identifier not found: %s", names[index])
+}
+
+func undeclaredAssignment(names []string, index int) *object.Error {
+	return newError(object.NameError, "Cannot assign to undeclared variable: %s", names[index])
+}
+
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,240 @@
+package vm
+
+import (
+	"ast"
+	"compiler"
+	"evaluator"
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	"go/token"
+	"lexer"
+	"object"
+	"os"
+	"parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// evaluatorTestInputs returns the programs of the evaluator test tables, the
+// input fields of the test cases in ../evaluator. The VM must produce the same
+// result for each of them.
+func evaluatorTestInputs(t *testing.T) []string {
+	fset := token.NewFileSet()
+	packages, err := goparser.ParseDir(fset, "../evaluator", func(info os.FileInfo) bool {
+		return strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("parsing the evaluator tests failed: %s", err)
+	}
+
+	var inputs []string
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			goast.Inspect(file, func(node goast.Node) bool {
+				table, ok := node.(*goast.CompositeLit)
+				if !ok || !isTestTable(table) {
+					return true
+				}
+				for _, element := range table.Elts {
+					testCase, ok := element.(*goast.CompositeLit)
+					if !ok || len(testCase.Elts) == 0 {
+						continue
+					}
+					literal, ok := testCase.Elts[0].(*goast.BasicLit)
+					if !ok || literal.Kind != token.STRING {
+						continue
+					}
+					input, err := strconv.Unquote(literal.Value)
+					if err != nil {
+						t.Fatalf("%s: %s", fset.Position(literal.Pos()), err)
+					}
+					inputs = append(inputs, input)
+				}
+				return false
+			})
+		}
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("no test cases found in the evaluator tests")
+	}
+	return inputs
+}
+
+// isTestTable reports whether table is a slice of test cases whose first field
+// is the input.
+func isTestTable(table *goast.CompositeLit) bool {
+	slice, ok := table.Type.(*goast.ArrayType)
+	if !ok {
+		return false
+	}
+	testCase, ok := slice.Elt.(*goast.StructType)
+	if !ok || len(testCase.Fields.List) == 0 {
+		return false
+	}
+	first := testCase.Fields.List[0]
+	return len(first.Names) > 0 && first.Names[0].Name == "input"
+}
+
+func TestParityWithEvaluator(t *testing.T) {
+	for _, input := range evaluatorTestInputs(t) {
+		expected := evaluator.Eval(parse(input), object.NewEnvironment())
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			// the compiler rejects some errors before the program runs
+			if _, ok := expected.(*object.Error); !ok {
+				t.Errorf("compiler error but evaluator succeeded.\n  Case=%s\n  evaluator=%s\n  compiler=%s",
+					input, inspect(expected), err)
+			}
+			continue
+		}
+		machine := New(comp.Bytecode())
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		actual := machine.Result()
+
+		if inspect(actual) != inspect(expected) {
+			t.Errorf("result differs from evaluator.\n  Case=%s\n  evaluator=%s\n  vm=%s",
+				input, inspect(expected), inspect(actual))
+			continue
+		}
+
+		if expectedError, ok := expected.(*object.Error); ok {
+			actualError := actual.(*object.Error)
+			if actualError.Pos != expectedError.Pos || actualError.End != expectedError.End {
+				t.Errorf("error position differs from evaluator.\n  Case=%s\n  evaluator=%s-%s\n  vm=%s-%s",
+					input, expectedError.Pos, expectedError.End, actualError.Pos, actualError.End)
+			}
+			if actualError.Traceback() != expectedError.Traceback() {
+				t.Errorf("traceback differs from evaluator.\n  Case=%s\n  evaluator=%s\n  vm=%s",
+					input, expectedError.Traceback(), actualError.Traceback())
+			}
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// mutual recursion between locals declared after the closures are created
+		{`
+let f = fn(n) {
+	let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+	let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+	isEven(n)
+};
+f(10);
+`, "true"},
+		{`let f = fn() { let a = b; let b = 1; a }; f()`, "ERROR: Identifier not found: b"},
+		{`let f = fn(a) { a }; f(1, 2)`, "ERROR: wrong number of arguments for f. got=2, want=1"},
+		{`let f = fn() { f() }; f()`, "ERROR: stack overflow"},
+		{`1(2)`, "ERROR: not a function: INTEGER"},
+		{`let a = 1;`, "<nil>"},
+	}
+
+	for _, tt := range tests {
+		actual := inspect(run(t, tt.input))
+		if actual != tt.expected {
+			t.Errorf("wrong result.\n  Case=%s\n  expected=%s\n  got=%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+// TestLimits checks that programs the evaluator runs do not exceed the stack,
+// the frames or the operands of the VM, and that both engines overflow at the
+// same call depth.
+func TestLimits(t *testing.T) {
+	var locals, parameters, arguments []string
+	for i := 0; i < 300; i++ {
+		locals = append(locals, fmt.Sprintf("let v%d = %d;", i, i))
+		parameters = append(parameters, fmt.Sprintf("a%d", i))
+		arguments = append(arguments, fmt.Sprintf("%d", i))
+	}
+	countdown := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; "
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{countdown + "f(5000)", "5000"},
+		{countdown + fmt.Sprintf("f(%d)", evaluator.MaxCallDepth-1), fmt.Sprintf("%d", evaluator.MaxCallDepth-1)},
+		{countdown + fmt.Sprintf("f(%d)", evaluator.MaxCallDepth), "ERROR: stack overflow"},
+		{"len([" + strings.Repeat("1, ", 2999) + "1])", "3000"},
+		{"fn() { " + strings.Join(locals, " ") + " v0 + v299 }()", "299"},
+		{"fn(" + strings.Join(parameters, ", ") + ") { a0 + a299 }(" + strings.Join(arguments, ", ") + ")", "299"},
+	}
+
+	for _, tt := range tests {
+		evaluated := evaluator.Eval(parse(tt.input), object.NewEnvironment())
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong evaluator result.\n  Case=%.80s\n  expected=%s\n  got=%s", tt.input, tt.expected, inspect(evaluated))
+		}
+		actual := run(t, tt.input)
+		if inspect(actual) != tt.expected {
+			t.Errorf("wrong result.\n  Case=%.80s\n  expected=%s\n  got=%s", tt.input, tt.expected, inspect(actual))
+		}
+		if err, ok := evaluated.(*object.Error); ok {
+			if actualError, ok := actual.(*object.Error); ok && actualError.Traceback() != err.Traceback() {
+				t.Errorf("traceback differs from evaluator.\n  Case=%.80s\n  evaluator=%s\n  vm=%s",
+					tt.input, err.Traceback(), actualError.Traceback())
+			}
+		}
+	}
+}
+
+func TestGlobalsState(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+
+	var result object.Object
+	for _, line := range []string{"let counter = fn(x) { x + step };", "let step = 2;", "counter(40)"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(line)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsState(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		result = machine.Result()
+	}
+
+	if inspect(result) != "42" {
+		t.Errorf("wrong result. expected=42, got=%s", inspect(result))
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func run(t *testing.T, input string) object.Object {
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	return machine.Result()
+}
+
+func inspect(o object.Object) string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Inspect()
+}